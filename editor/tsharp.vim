@@ -14,7 +14,7 @@ endif
 syntax keyword tsharpKeywords import block do end if elif else for try except break dup drop swap print println rot over input exit free isdigit assert
 
 " Type keywords
-syntax keyword tsharpType int string type bool list error
+syntax keyword tsharpType int float string type bool list map quote module error
 
 " Boolean keywords
 syntax keyword tsharpBoolean true false
@@ -27,10 +27,10 @@ syntax region tsharpString start=/\v"/ skip=/\v\\./ end=/\v"/
 syntax region tsharpString start=/\v'/ skip=/\v\\./ end=/\v'/
 
 " Numbers
-syntax match tsharpNumbers '\d\+'
+syntax match tsharpNumbers '\d\+\(\.\d\+\)\=\([eE][-+]\=\d\+\)\='
 
 " Exceptions
-syntax keyword tsharpExceptions try except
+syntax keyword tsharpExceptions try except finally exception raise rethrow
 
 " Set highlights
 highlight default link tsharpKeywords Repeat
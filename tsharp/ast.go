@@ -0,0 +1,263 @@
+package tsharp
+
+import (
+	"math/big"
+	"os"
+)
+
+
+// -----------------------------
+// ------------ AST ------------
+// -----------------------------
+
+type NodePosition struct {
+	FileName string
+	Line int
+	Column int
+}
+
+type AsStr struct {
+	StringValue string
+}
+
+func (node AsStr) node() {}
+
+type AsInt struct {
+	IntValue int
+}
+
+func (node AsInt) node() {}
+
+// AsBigInt is an <int> that does not fit in a Go int.
+type AsBigInt struct {
+	BigValue *big.Int
+}
+
+func (node AsBigInt) node() {}
+
+type AsFloat struct {
+	FloatValue float64
+}
+
+func (node AsFloat) node() {}
+
+type AsBool struct {
+	BoolValue bool
+}
+
+func (node AsBool) node() {}
+
+type AsFile struct {
+	FileAddress *os.File
+	FileName string
+}
+
+func (node AsFile) node() {}
+
+type NewList struct {
+	ListBody AST
+}
+
+func (node NewList) node() {}
+
+type NewMap struct {
+	MapBody AST
+	Position NodePosition
+}
+
+func (node NewMap) node() {}
+
+// AsMap keeps its keys in insertion order. Index maps MapKey of a key to
+// its position in Keys and Values.
+type AsMap struct {
+	Keys []AST
+	Values []AST
+	Index map[string]int
+}
+
+func (node AsMap) node() {}
+
+type AsList struct {
+	ListArgs []AST
+}
+
+func (node AsList) node() {}
+
+// AsId is a built-in word. Tail is set on a `call` in tail position.
+type AsId struct {
+	name string
+	Position NodePosition
+	Tail bool
+}
+
+func (node AsId) node() {}
+
+// Import is `import "path"`, or `import "path" as name`.
+type Import struct {
+	Path string
+	Name string
+	Position NodePosition
+}
+
+func (node Import) node() {}
+
+// AsModule is a module made by `import`. Env holds its top-level names;
+// when the VM ran it, Names are the names of the slots.
+type AsModule struct {
+	Name string
+	Path string
+	Env *Env
+	Names []string
+}
+
+func (node AsModule) node() {}
+
+type Include struct {
+	FileName string
+	Position NodePosition
+}
+
+func (node Include) node() {}
+
+type Assert struct {
+	Position NodePosition
+	Message string
+}
+
+func (node Assert) node() {}
+
+type Compare struct {
+	op uint8
+	Position NodePosition
+}
+
+func (node Compare) node() {}
+
+type AsError struct {
+	err ErrorType
+	message string
+	Position NodePosition
+	name string
+}
+
+func (node AsError) node() {}
+
+// Name is the name of the error type, qualified with the module name for
+// an `exception` declared in a module.
+func (node AsError) Name() string {
+	if node.name != "" {
+		return node.name
+	}
+	return node.err.String()
+}
+
+type AsBinop struct {
+	op uint8
+	Position NodePosition
+}
+
+func (node AsBinop) node() {}
+
+type AsPush struct {
+	value AST
+}
+
+func (node AsPush) node() {}
+
+type AsType struct {
+	TypeValue string
+}
+
+func (node AsType) node() {}
+
+// Vardef is `-> name`, or `=> name` when Outer is set.
+type Vardef struct {
+	Name string
+	Position NodePosition
+	Outer bool
+}
+
+func (node Vardef) node() {}
+
+// Var is a name that is read, or called when it holds a block. Member is
+// what follows the first `.` in `math.sqrt`, a name in the module Name
+// holds. Tail is set when it is in tail position in a block body.
+type Var struct {
+	Name string
+	Position NodePosition
+	Tail bool
+	Member string
+}
+
+func (node Var) node() {}
+
+type StackEffect struct {
+	Inputs []string
+	Outputs []string
+}
+
+type Blockdef struct {
+	Name string
+	Position NodePosition
+	Signature *StackEffect
+	BlockBody AST
+	Code *Chunk
+	Env *Env
+}
+
+func (node Blockdef) node() {}
+
+// AsQuote is a block without a name pushed on the stack, to be run
+// later with `call`.
+type AsQuote struct {
+	Block Blockdef
+}
+
+func (node AsQuote) node() {}
+
+type Errordef struct {
+	Name string
+	Position NodePosition
+}
+
+func (node Errordef) node() {}
+
+type If struct {
+	IfOp AST
+	Position NodePosition
+	IfBody AST
+	ElifOps []AST
+	ElifPositions []NodePosition
+	ElifBodys []AST
+	ElseBody AST
+}
+
+func (node If) node() {}
+
+type For struct {
+	ForOp AST
+	Position NodePosition
+	ForBody AST
+}
+
+func (node For) node() {}
+
+type Try struct {
+	Position NodePosition
+	TryBody AST
+	ExceptErrors []AST
+	ExceptNames []string
+	ExceptPositions []NodePosition
+	ExceptBodys []AST
+	FinallyBody AST
+}
+
+func (node Try) node() {}
+
+type AsStatements []AST
+
+func (node AsStatements) node() {}
+
+type AST interface {
+	node()
+}
@@ -0,0 +1,88 @@
+package tsharp
+
+
+// -----------------------------
+// --------- Built-ins ---------
+// -----------------------------
+
+type Builtin struct {
+	Effect string
+	Description string
+}
+
+// Builtins are the words the parser turns into AsId nodes. The stack
+// effects are the ones documented in DOC/docs.md.
+var Builtins = map[string]Builtin{
+	"dup":       {"a -- a a", "duplicate an element on top of the stack."},
+	"drop":      {"a -- ", "drops the top element of the stack."},
+	"swap":      {"a b -- b a", "swap 2 elements on the top of the stack."},
+	"print":     {"a -- ", "print the element on top of the stack and remove it from the stack."},
+	"println":   {"a -- ", "`print` with a new line."},
+	"rot":       {"a b c -- b c a", "rotate the top three stack elements."},
+	"over":      {"a b -- a b a", "duplicate the second value on the stack."},
+	"input":     {" -- <string value>", "user input."},
+	"exit":      {"<int value> -- ", "exit. the <int> on top of the stack, from 0 to 255, is the exit status; an empty stack exits with 0."},
+	"free":      {"a b c -- ", "drop all elements of the stack."},
+	"break":     {" -- ", "leave the innermost for loop."},
+	"inc":       {"<int value> -- <int value>", "add 1 to the <int> on top of the stack."},
+	"dec":       {"<int value> -- <int value>", "subtract 1 from the <int> on top of the stack."},
+	"append":    {"<list value> a -- <list value>", "append an element to the list."},
+	"read":      {"<list value> <int value> -- a", "read the element at the index of a list or a string."},
+	"replace":   {"<list value> a <int value> -- <list value>", "replace the element at the index of the list."},
+	"remove":    {"<list value> <int value> -- <list value>", "remove the element at the index of the list."},
+	"in":        {"a <list value> -- <bool value>", "check the element is in the list."},
+	"len":       {"<list value> -- <int value>", "the length of a list or a string."},
+	"typeof":    {"a -- <type value>", "the type of the element on top of the stack."},
+	"isdigit":   {"<string value> -- <bool value>", "check the top string type element is digit. push the bool value."},
+	"atoi":      {"<string value> -- <int value>", "string to int."},
+	"itoa":      {"<int value> -- <string value>", "int to string."},
+	"atof":      {"<string value> -- <float value>", "string to float. a string that is not a number raises ValueError."},
+	"ftoa":      {"<float value> -- <string value>", "float to string."},
+	"itof":      {"<int value> -- <float value>", "int to float."},
+	"ftoi":      {"<float value> -- <int value>", "float to int, truncated toward zero. NaN and infinities raise ValueError."},
+	"b":         {"<string value> -- <list value>", "the bytes of the string as a list of <int>."},
+	"uniquote":  {"<string value> -- <string value>", "interpret the escape sequences in the string."},
+	"split":     {"<string value> <string value> -- <list value>", "split the string at each separator. an empty separator splits it into characters."},
+	"join":      {"<list value> <string value> -- <string value>", "join the elements of the list with the separator between them."},
+	"slice":     {"<string value> <int value> <int value> -- <string value>", "the characters of a string, or the elements of a list, from start up to end."},
+	"find":      {"<string value> <string value> -- <int value>", "the index of the first occurrence of the substring, or -1."},
+	"replace-all": {"<string value> <string value> <string value> -- <string value>", "replace each occurrence of the old substring with the new one."},
+	"trim":      {"<string value> -- <string value>", "remove the white space around the string."},
+	"upper":     {"<string value> -- <string value>", "the string in upper case."},
+	"lower":     {"<string value> -- <string value>", "the string in lower case."},
+	"starts-with": {"<string value> <string value> -- <bool value>", "check the string starts with the prefix."},
+	"ends-with": {"<string value> <string value> -- <bool value>", "check the string ends with the suffix."},
+	"repeat":    {"<string value> <int value> -- <string value>", "the string repeated the number of times."},
+	"format":    {"a... <string value> -- <string value>", "fill each `{}` of the string with an element below it, the deepest first."},
+	"system":    {"<string value> -- ", "run a shell command and print its output."},
+	"fopen":     {"<string value> -- <file value>", "open a file, creating it if needed."},
+	"fopen-read": {"<string value> -- <file value>", "open a file to read it, raising FileNotFoundError if it is missing."},
+	"fopen-append": {"<string value> -- <file value>", "open a file to write at its end, creating it if needed."},
+	"fclose":    {"<file value> -- ", "close the file."},
+	"fwrite":    {"<string value> <file value> -- ", "write the string to the file."},
+	"fread":     {"<file value> -- <string value>", "read the whole file."},
+	"ftruncate": {"<file value> -- ", "truncate the file."},
+	"raise":     {"<string value> <error value> -- ", "raise the error with the message."},
+	"rethrow":   {"<error value> -- ", "raise a caught error again."},
+	"errmsg":    {"<error value> -- <string value>", "the message of a caught error."},
+	"assert-equal": {"actual expected -- ", "raise an AssertionError unless the values are equal, like `==`."},
+	"assert-not-equal": {"actual other -- ", "raise an AssertionError if the values are equal."},
+	"assert-depth": {"<int value> -- ", "raise an AssertionError unless the stack has that many elements."},
+	"assert-raises": {"<quote value> <error value> -- ", "run the quotation and raise an AssertionError unless it raised the error."},
+	"get":       {"<map value> key -- a", "the value of the key in the map."},
+	"set":       {"<map value> key a -- <map value>", "set the value of the key in the map."},
+	"delete":    {"<map value> key -- <map value>", "remove the key from the map."},
+	"has-key":   {"<map value> key -- <bool value>", "check the key is in the map."},
+	"keys":      {"<map value> -- <list value>", "the keys of the map, in insertion order."},
+	"values":    {"<map value> -- <list value>", "the values of the map, in insertion order."},
+	"call":      {"<quote value> -- ", "run the quotation."},
+	"collect":   {"<list value> <quote value> -- <list value>", "run the quotation on each element and collect the results."},
+	"filter":    {"<list value> <quote value> -- <list value>", "keep the elements the quotation leaves `true` for."},
+	"each":      {"<list value> <quote value> -- ", "run the quotation on each element."},
+	"reduce":    {"<list value> a <quote value> -- a", "fold the elements into `a` with the quotation."},
+}
+
+func IsBuiltin(name string) bool {
+	_, ok := Builtins[name]
+	return ok
+}
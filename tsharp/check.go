@@ -0,0 +1,852 @@
+package tsharp
+
+import (
+	"fmt"
+	"os"
+)
+
+
+// -----------------------------
+// ---------- Checker ----------
+// -----------------------------
+
+// TypeStack is the stack as the checker sees it. Types holds the types of
+// the elements pushed so far; when Open is set the stack may have more
+// elements below them, and Borrowed counts how many of those were used.
+type TypeStack struct {
+	Types []string
+	Open bool
+	Borrowed int
+	Unknown bool
+	Dead bool
+}
+
+type BlockEffect struct {
+	Inputs []string
+	Outputs []string
+	Known bool
+	Dead bool
+}
+
+type Checker struct {
+	Errors []*Error
+	Globals map[string]string
+	Blocks map[string]Blockdef
+	Effects map[string]*BlockEffect
+	Defined map[string]bool
+	Checking map[string]bool
+	HasInclude bool
+}
+
+func CheckerInit() *Checker {
+	return &Checker{
+		Globals: map[string]string{"argv": "list"},
+		Blocks: map[string]Blockdef{},
+		Effects: map[string]*BlockEffect{},
+		Defined: map[string]bool{"argv": true},
+		Checking: map[string]bool{},
+	}
+}
+
+// Check walks the AST and reports the stack underflows, type errors and
+// unbalanced branches it can find without running the program.
+func Check(ast AST) []*Error {
+	checker := CheckerInit()
+	checker.Collect(ast)
+	state := &TypeStack{Types: []string{}}
+	checker.CheckBody(ast, state, nil)
+	return checker.Errors
+}
+
+func CheckFile(FileName string) []*Error {
+	file, OpenErr := os.Open(FileName)
+	if OpenErr != nil {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:FileNotFoundError:0:0: cannot open file `%s`.", FileName, FileName)
+		err.Type = FileNotFoundError
+		err.Position = NodePosition{FileName, 0, 0}
+		return []*Error{&err}
+	}
+	defer file.Close()
+	parser, err := ParserInit(LexerInit(file, FileName))
+	if err != nil {
+		return []*Error{err}
+	}
+	ast, err := ParserParse(parser)
+	if err != nil {
+		return []*Error{err}
+	}
+	return Check(ast)
+}
+
+func (checker *Checker) Report(Type ErrorType, position NodePosition, format string, args ...interface{}) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:%s:%d:%d: %s", position.FileName, Type, position.Line, position.Column, fmt.Sprintf(format, args...))
+	err.Type = Type
+	err.Position = position
+	checker.Errors = append(checker.Errors, &err)
+}
+
+// Collect records every name the program can define, and every block, so
+// blocks can be called before the checker reaches their definition.
+func (checker *Checker) Collect(node AST) {
+	if node == nil {
+		return
+	}
+	switch node.(type) {
+		case AsStatements:
+			for i := 0; i < len(node.(AsStatements)); i++ {
+				checker.Collect(node.(AsStatements)[i])
+			}
+		case AsPush:
+			switch node.(AsPush).value.(type) {
+				case NewList: checker.Collect(node.(AsPush).value.(NewList).ListBody)
+				case NewMap: checker.Collect(node.(AsPush).value.(NewMap).MapBody)
+				case AsQuote: checker.Collect(node.(AsPush).value.(AsQuote).Block.BlockBody)
+			}
+		case Vardef:
+			checker.Defined[node.(Vardef).Name] = true
+		case Errordef:
+			checker.Defined[node.(Errordef).Name] = true
+		case Include:
+			checker.HasInclude = true
+		case Import:
+			checker.Defined[node.(Import).Name] = true
+		case Blockdef:
+			checker.Defined[node.(Blockdef).Name] = true
+			checker.Blocks[node.(Blockdef).Name] = node.(Blockdef)
+			checker.Collect(node.(Blockdef).BlockBody)
+		case If:
+			checker.Collect(node.(If).IfOp)
+			checker.Collect(node.(If).IfBody)
+			for i := 0; i < len(node.(If).ElifOps); i++ {
+				checker.Collect(node.(If).ElifOps[i])
+				checker.Collect(node.(If).ElifBodys[i])
+			}
+			checker.Collect(node.(If).ElseBody)
+		case For:
+			checker.Collect(node.(For).ForOp)
+			checker.Collect(node.(For).ForBody)
+		case Try:
+			checker.Collect(node.(Try).TryBody)
+			for i := 0; i < len(node.(Try).ExceptBodys); i++ {
+				if node.(Try).ExceptNames[i] != "" {
+					checker.Defined[node.(Try).ExceptNames[i]] = true
+				}
+				checker.Collect(node.(Try).ExceptBodys[i])
+			}
+			checker.Collect(node.(Try).FinallyBody)
+	}
+}
+
+func (state *TypeStack) Copy() *TypeStack {
+	types := make([]string, len(state.Types))
+	copy(types, state.Types)
+	return &TypeStack{types, state.Open, state.Borrowed, state.Unknown, state.Dead}
+}
+
+func (state *TypeStack) Depth() int {
+	return len(state.Types) - state.Borrowed
+}
+
+func (state *TypeStack) Push(types ...string) {
+	state.Types = append(state.Types, types...)
+}
+
+// Pop takes n types from the stack. Missing elements of an open or unknown
+// stack are `any`; missing elements of a closed stack are an underflow.
+func (checker *Checker) Pop(state *TypeStack, n int, word string, position NodePosition) []string {
+	types := make([]string, n)
+	missing := n - len(state.Types)
+	if missing > 0 {
+		if !state.Open && !state.Unknown {
+			checker.Report(StackUnderflowError, position, "`%s` expected %d element(s) in the stack, but the stack has %d.", word, n, len(state.Types))
+			state.Unknown = true
+		} else if !state.Unknown {
+			state.Borrowed += missing
+		}
+		for i := 0; i < missing; i++ {
+			types[i] = "any"
+		}
+		copy(types[missing:], state.Types)
+		state.Types = state.Types[:0]
+		return types
+	}
+	copy(types, state.Types[len(state.Types)-n:])
+	state.Types = state.Types[:len(state.Types)-n]
+	return types
+}
+
+func (checker *Checker) Expect(got string, want []string, word string, position NodePosition) bool {
+	if got == "any" {
+		return true
+	}
+	for i := 0; i < len(want); i++ {
+		if want[i] == "any" || want[i] == got {
+			return true
+		}
+	}
+	expected := ""
+	for i := 0; i < len(want); i++ {
+		if i > 0 {
+			expected += " or "
+		}
+		expected += "<" + want[i] + ">"
+	}
+	checker.Report(TypeError, position, "`%s` expected %s type element in the stack, but got <%s>.", word, expected, got)
+	return false
+}
+
+func (state *TypeStack) SetUnknown() {
+	state.Types = state.Types[:0]
+	state.Unknown = true
+}
+
+func MergeType(a string, b string) string {
+	if a == b {
+		return a
+	}
+	return "any"
+}
+
+// Merge joins the stacks of the branches that can reach the same point.
+func (checker *Checker) Merge(states []*TypeStack, what string, position NodePosition) *TypeStack {
+	var live []*TypeStack
+	for i := 0; i < len(states); i++ {
+		if !states[i].Dead {
+			live = append(live, states[i])
+		}
+	}
+	if len(live) == 0 {
+		return &TypeStack{Types: []string{}, Dead: true}
+	}
+	result := live[0].Copy()
+	for i := 1; i < len(live); i++ {
+		other := live[i]
+		if result.Unknown || other.Unknown {
+			result.SetUnknown()
+			continue
+		}
+		if result.Depth() != other.Depth() {
+			checker.Report(StackUnderflowError, position, "%s leave different stack depths (%d and %d).", what, result.Depth(), other.Depth())
+			result.SetUnknown()
+			continue
+		}
+		a := result.Types
+		b := other.Types
+		borrowed := result.Borrowed
+		if other.Borrowed > borrowed {
+			borrowed = other.Borrowed
+		}
+		for len(a) < len(b) {
+			a = append([]string{"any"}, a...)
+		}
+		for len(b) < len(a) {
+			b = append([]string{"any"}, b...)
+		}
+		merged := make([]string, len(a))
+		for j := 0; j < len(a); j++ {
+			merged[j] = MergeType(a[j], b[j])
+		}
+		result.Types = merged
+		result.Borrowed = borrowed
+		result.Open = result.Open || other.Open
+	}
+	return result
+}
+
+func (checker *Checker) SetVar(name string, Type string, locals map[string]string) {
+	if locals == nil {
+		if old, ok := checker.Globals[name]; ok {
+			Type = MergeType(old, Type)
+		}
+		checker.Globals[name] = Type
+		return
+	}
+	if old, ok := locals[name]; ok {
+		Type = MergeType(old, Type)
+	}
+	locals[name] = Type
+}
+
+// AssignVar is SetVar for `=>`, which assigns the variable it finds. A
+// variable of a block around this one is not tracked.
+func (checker *Checker) AssignVar(name string, Type string, locals map[string]string) {
+	if old, ok := locals[name]; ok {
+		locals[name] = MergeType(old, Type)
+	} else if old, ok := checker.Globals[name]; ok {
+		checker.Globals[name] = MergeType(old, Type)
+	}
+}
+
+// Effect returns the stack effect of a block, from its signature or by
+// checking its body on an open stack.
+func (checker *Checker) Effect(name string) *BlockEffect {
+	if effect, ok := checker.Effects[name]; ok {
+		return effect
+	}
+	block := checker.Blocks[name]
+	if block.Signature != nil {
+		effect := &BlockEffect{block.Signature.Inputs, block.Signature.Outputs, true, false}
+		checker.Effects[name] = effect
+		return effect
+	}
+	if checker.Checking[name] {
+		return &BlockEffect{Known: false}
+	}
+	checker.Checking[name] = true
+	state := &TypeStack{Types: []string{}, Open: true}
+	saved := checker.Errors
+	checker.CheckBody(block.BlockBody, state, map[string]string{})
+	checker.Errors = saved
+	checker.Checking[name] = false
+	effect := &BlockEffect{Known: !state.Unknown, Dead: state.Dead}
+	if effect.Known {
+		for i := 0; i < state.Borrowed; i++ {
+			effect.Inputs = append(effect.Inputs, "any")
+		}
+		effect.Outputs = append(effect.Outputs, state.Types...)
+	}
+	checker.Effects[name] = effect
+	return effect
+}
+
+func (checker *Checker) CheckBlockdef(block Blockdef) {
+	if block.Signature == nil {
+		state := &TypeStack{Types: []string{}, Open: true}
+		checker.Checking[block.Name] = true
+		checker.CheckBody(block.BlockBody, state, map[string]string{})
+		checker.Checking[block.Name] = false
+		return
+	}
+	state := &TypeStack{Types: append([]string{}, block.Signature.Inputs...)}
+	checker.Checking[block.Name] = true
+	checker.CheckBody(block.BlockBody, state, map[string]string{})
+	checker.Checking[block.Name] = false
+	if state.Dead || state.Unknown {
+		return
+	}
+	outputs := block.Signature.Outputs
+	if len(state.Types) != len(outputs) {
+		checker.Report(StackUnderflowError, block.Position, "block `%s` should leave ( %s ) in the stack, but leaves %d element(s).", block.Name, StackEffectString(outputs), len(state.Types))
+		return
+	}
+	for i := 0; i < len(outputs); i++ {
+		if outputs[i] != "any" && state.Types[i] != "any" && outputs[i] != state.Types[i] {
+			checker.Report(TypeError, block.Position, "block `%s` should leave <%s> type as output %d, but leaves <%s>.", block.Name, outputs[i], i+1, state.Types[i])
+		}
+	}
+}
+
+func (checker *Checker) CheckCall(name string, position NodePosition, state *TypeStack) {
+	effect := checker.Effect(name)
+	if !effect.Known {
+		state.SetUnknown()
+		return
+	}
+	inputs := checker.Pop(state, len(effect.Inputs), name, position)
+	for i := 0; i < len(inputs); i++ {
+		checker.Expect(inputs[i], []string{effect.Inputs[i]}, name, position)
+	}
+	state.Push(effect.Outputs...)
+	if effect.Dead {
+		state.Dead = true
+	}
+}
+
+func (checker *Checker) CheckVar(node Var, state *TypeStack, locals map[string]string) {
+	if node.Member != "" {
+		// a member may be a block, so its effect is not known
+		if _, ok := locals[node.Name]; !ok && !checker.Defined[node.Name] && !checker.HasInclude {
+			checker.Report(NameError, node.Position, "name `%s` is not defined.", node.Name)
+		}
+		state.SetUnknown()
+		return
+	}
+	if locals != nil {
+		if Type, ok := locals[node.Name]; ok {
+			state.Push(Type)
+			return
+		}
+	}
+	if _, ok := checker.Blocks[node.Name]; ok {
+		checker.CheckCall(node.Name, node.Position, state)
+		return
+	}
+	if Type, ok := checker.Globals[node.Name]; ok {
+		state.Push(Type)
+		return
+	}
+	if !checker.Defined[node.Name] && !checker.HasInclude {
+		checker.Report(NameError, node.Position, "name `%s` is not defined.", node.Name)
+	}
+	state.Push("any")
+}
+
+func LiteralType(node AST) string {
+	switch node.(type) {
+		case NewList: return "list"
+		case NewMap: return "map"
+		case AsError: return "error"
+	}
+	if name := TypeName(node); name != "" {
+		return name
+	}
+	return "any"
+}
+
+func (checker *Checker) CheckBody(node AST, state *TypeStack, locals map[string]string) {
+	if node == nil {
+		return
+	}
+	for i := 0; i < len(node.(AsStatements)); i++ {
+		if state.Dead {
+			return
+		}
+		if i > 0 && checker.CheckFormat(node.(AsStatements)[i-1], node.(AsStatements)[i], state) {
+			continue
+		}
+		checker.CheckNode(node.(AsStatements)[i], state, locals)
+	}
+}
+
+// CheckFormat checks a `format` right after its format string, which
+// tells how many elements it takes.
+func (checker *Checker) CheckFormat(prev AST, node AST, state *TypeStack) bool {
+	word, ok := node.(AsId)
+	if !ok || word.name != "format" {
+		return false
+	}
+	push, ok := prev.(AsPush)
+	if !ok {
+		return false
+	}
+	format, ok := push.value.(AsStr)
+	if !ok {
+		return false
+	}
+	checker.Pop(state, len(FormatParts(format.StringValue)), "format", word.Position)
+	state.Push("string")
+	return true
+}
+
+func (checker *Checker) CheckCondition(node AST, position NodePosition, what string, state *TypeStack, locals map[string]string) {
+	checker.CheckBody(node, state, locals)
+	if state.Dead {
+		return
+	}
+	cond := checker.Pop(state, 1, what, position)
+	checker.Expect(cond[0], []string{"bool"}, what, position)
+}
+
+func (checker *Checker) CheckNode(node AST, state *TypeStack, locals map[string]string) {
+	switch node.(type) {
+		case AsPush:
+			value := node.(AsPush).value
+			if _, ok := value.(Var); ok {
+				checker.CheckVar(value.(Var), state, locals)
+			} else {
+				switch value.(type) {
+					case NewList: checker.CheckBody(value.(NewList).ListBody, &TypeStack{Types: []string{}}, locals)
+					case NewMap: checker.CheckBody(value.(NewMap).MapBody, &TypeStack{Types: []string{}}, locals)
+					case AsQuote: checker.CheckBlockdef(value.(AsQuote).Block)
+				}
+				state.Push(LiteralType(value))
+			}
+		case AsId:
+			checker.CheckWord(node.(AsId), state)
+		case AsBinop:
+			position := node.(AsBinop).Position
+			word := RetTokenAsStr(node.(AsBinop).op)
+			args := checker.Pop(state, 2, word, position)
+			if node.(AsBinop).op == TOKEN_PLUS && (args[0] == "string" || args[1] == "string") {
+				checker.Expect(args[0], []string{"string"}, word, position)
+				checker.Expect(args[1], []string{"string"}, word, position)
+				state.Push("string")
+			} else if node.(AsBinop).op == TOKEN_PLUS && args[0] == "any" && args[1] == "any" {
+				state.Push("any")
+			} else {
+				if checker.Expect(args[0], []string{"int", "float"}, word, position) {
+					checker.Expect(args[1], []string{"int", "float"}, word, position)
+				}
+				if args[0] == "float" || args[1] == "float" {
+					state.Push("float")
+				} else if args[0] == "int" && args[1] == "int" {
+					state.Push("int")
+				} else {
+					state.Push("any")
+				}
+			}
+		case Compare:
+			position := node.(Compare).Position
+			word := RetTokenAsStr(node.(Compare).op)
+			args := checker.Pop(state, 2, word, position)
+			switch node.(Compare).op {
+				case TOKEN_OR, TOKEN_AND:
+					checker.Expect(args[0], []string{"bool"}, word, position)
+					checker.Expect(args[1], []string{"bool"}, word, position)
+				case TOKEN_LESS_THAN, TOKEN_LESS_EQUALS, TOKEN_GREATER_THAN, TOKEN_GREATER_EQUALS:
+					checker.Expect(args[0], []string{"int", "float"}, word, position)
+					checker.Expect(args[1], []string{"int", "float"}, word, position)
+			}
+			state.Push("bool")
+		case Vardef:
+			if node.(Vardef).Outer {
+				Type := checker.Pop(state, 1, "=>", node.(Vardef).Position)
+				checker.AssignVar(node.(Vardef).Name, Type[0], locals)
+				break
+			}
+			Type := checker.Pop(state, 1, "->", node.(Vardef).Position)
+			checker.SetVar(node.(Vardef).Name, Type[0], locals)
+		case Blockdef:
+			checker.CheckBlockdef(node.(Blockdef))
+		case Errordef:
+			checker.Globals[node.(Errordef).Name] = "error"
+		case Include:
+			checker.Globals = map[string]string{}
+			state.SetUnknown()
+		case Import:
+			checker.SetVar(node.(Import).Name, "module", locals)
+		case Assert:
+			cond := checker.Pop(state, 1, "assert", node.(Assert).Position)
+			checker.Expect(cond[0], []string{"bool"}, "assert", node.(Assert).Position)
+		case AsStatements:
+			checker.CheckBody(node, state, locals)
+		case If:
+			checker.CheckIf(node.(If), state, locals)
+		case For:
+			checker.CheckFor(node.(For), state, locals)
+		case Try:
+			checker.CheckTry(node.(Try), state, locals)
+	}
+}
+
+func (checker *Checker) CheckIf(node If, state *TypeStack, locals map[string]string) {
+	checker.CheckCondition(node.IfOp, node.Position, "if", state, locals)
+	if state.Dead {
+		return
+	}
+	var branches []*TypeStack
+	branch := state.Copy()
+	checker.CheckBody(node.IfBody, branch, locals)
+	branches = append(branches, branch)
+	rest := state.Copy()
+	for i := 0; i < len(node.ElifOps); i++ {
+		checker.CheckCondition(node.ElifOps[i], node.ElifPositions[i], "elif", rest, locals)
+		branch := rest.Copy()
+		checker.CheckBody(node.ElifBodys[i], branch, locals)
+		branches = append(branches, branch)
+	}
+	if node.ElseBody != nil {
+		checker.CheckBody(node.ElseBody, rest, locals)
+	}
+	branches = append(branches, rest)
+	*state = *checker.Merge(branches, "if branches", node.Position)
+}
+
+func (checker *Checker) CheckFor(node For, state *TypeStack, locals map[string]string) {
+	before := state.Depth()
+	WasUnknown := state.Unknown
+	checker.CheckCondition(node.ForOp, node.Position, "for", state, locals)
+	if state.Dead {
+		return
+	}
+	body := state.Copy()
+	checker.CheckBody(node.ForBody, body, locals)
+	if body.Dead || body.Unknown || WasUnknown {
+		if body.Unknown {
+			state.SetUnknown()
+		}
+		return
+	}
+	if body.Depth() != before {
+		checker.Report(StackUnderflowError, node.Position, "for loop body changes the stack depth by %+d on each iteration.", body.Depth()-before)
+		state.SetUnknown()
+		return
+	}
+	checker.CheckCondition(node.ForOp, node.Position, "for", body, locals)
+	*state = *checker.Merge([]*TypeStack{state, body}, "for loop exits", node.Position)
+}
+
+// CheckTry checks a try statement. An error in the body that an except
+// clause catches is not reported: the body stops there, and the stack is
+// the one of the handler.
+func (checker *Checker) CheckTry(node Try, state *TypeStack, locals map[string]string) {
+	body := state.Copy()
+	errors := len(checker.Errors)
+	checker.CheckBody(node.TryBody, body, locals)
+	found := checker.Errors[errors:]
+	checker.Errors = checker.Errors[:errors:errors]
+	for i := 0; i < len(found); i++ {
+		if TryCatches(node, found[i].Type) {
+			body.Dead = true
+		} else {
+			checker.Errors = append(checker.Errors, found[i])
+		}
+	}
+	branches := []*TypeStack{body}
+	for i := 0; i < len(node.ExceptBodys); i++ {
+		handler := &TypeStack{Types: []string{}, Unknown: true}
+		handler.Push("error")
+		if node.ExceptNames[i] != "" {
+			checker.Pop(handler, 1, "->", node.Position)
+			checker.SetVar(node.ExceptNames[i], "error", locals)
+		}
+		checker.CheckBody(node.ExceptBodys[i], handler, locals)
+		branches = append(branches, handler)
+	}
+	*state = *checker.Merge(branches, "try and except", node.Position)
+	if node.FinallyBody != nil {
+		checker.CheckBody(node.FinallyBody, state, locals)
+	}
+}
+
+// TryCatches tells if an except clause of the try statement catches the
+// errors of type Type.
+func TryCatches(node Try, Type ErrorType) bool {
+	for i := 0; i < len(node.ExceptErrors); i++ {
+		switch node.ExceptErrors[i].(type) {
+			case AsType: return true
+			case AsError:
+				if node.ExceptErrors[i].(AsError).err == Type {
+					return true
+				}
+		}
+	}
+	return false
+}
+
+func (checker *Checker) CheckWord(node AsId, state *TypeStack) {
+	position := node.Position
+	name := node.name
+	switch name {
+		case "dup":
+			a := checker.Pop(state, 1, name, position)
+			state.Push(a[0], a[0])
+		case "drop", "print", "println":
+			checker.Pop(state, 1, name, position)
+		case "swap":
+			a := checker.Pop(state, 2, name, position)
+			state.Push(a[1], a[0])
+		case "rot":
+			a := checker.Pop(state, 3, name, position)
+			state.Push(a[1], a[2], a[0])
+		case "over":
+			a := checker.Pop(state, 2, name, position)
+			state.Push(a[0], a[1], a[0])
+		case "input":
+			state.Push("string")
+		case "exit":
+			if len(state.Types) > 0 {
+				checker.Expect(state.Types[len(state.Types)-1], []string{"int"}, name, position)
+			}
+			state.Dead = true
+		case "rethrow":
+			state.Dead = true
+		case "raise":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			checker.Expect(a[1], []string{"error"}, name, position)
+			state.Dead = true
+		case "break":
+			state.Dead = true
+		case "free":
+			state.Types = state.Types[:0]
+			state.Open = false
+			state.Unknown = false
+			state.Borrowed = 0
+		case "inc", "dec":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"int"}, name, position)
+			state.Push("int")
+		case "append":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"list"}, name, position)
+			state.Push("list")
+		case "read":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"list", "string"}, name, position)
+			checker.Expect(a[1], []string{"int"}, name, position)
+			if a[0] == "string" {
+				state.Push("string")
+			} else {
+				state.Push("any")
+			}
+		case "replace":
+			a := checker.Pop(state, 3, name, position)
+			checker.Expect(a[0], []string{"list"}, name, position)
+			checker.Expect(a[2], []string{"int"}, name, position)
+			state.Push("list")
+		case "remove":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"list"}, name, position)
+			checker.Expect(a[1], []string{"int"}, name, position)
+			state.Push("list")
+		case "in":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[1], []string{"list"}, name, position)
+			state.Push("bool")
+		case "len":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"list", "string", "map"}, name, position)
+			state.Push("int")
+		case "typeof":
+			checker.Pop(state, 1, name, position)
+			state.Push("type")
+		case "isdigit":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			state.Push("bool")
+		case "atoi":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			state.Push("int")
+		case "itoa":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"int"}, name, position)
+			state.Push("string")
+		case "atof":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			state.Push("float")
+		case "ftoa":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"float"}, name, position)
+			state.Push("string")
+		case "itof":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"int"}, name, position)
+			state.Push("float")
+		case "ftoi":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"float"}, name, position)
+			state.Push("int")
+		case "b":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			state.Push("list")
+		case "uniquote", "trim", "upper", "lower":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			state.Push("string")
+		case "split":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			checker.Expect(a[1], []string{"string"}, name, position)
+			state.Push("list")
+		case "join":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"list"}, name, position)
+			checker.Expect(a[1], []string{"string"}, name, position)
+			state.Push("string")
+		case "slice":
+			a := checker.Pop(state, 3, name, position)
+			checker.Expect(a[0], []string{"string", "list"}, name, position)
+			checker.Expect(a[1], []string{"int"}, name, position)
+			checker.Expect(a[2], []string{"int"}, name, position)
+			if a[0] == "string" || a[0] == "list" {
+				state.Push(a[0])
+			} else {
+				state.Push("any")
+			}
+		case "find":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			checker.Expect(a[1], []string{"string"}, name, position)
+			state.Push("int")
+		case "starts-with", "ends-with":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			checker.Expect(a[1], []string{"string"}, name, position)
+			state.Push("bool")
+		case "replace-all":
+			a := checker.Pop(state, 3, name, position)
+			for i := 0; i < 3; i++ {
+				checker.Expect(a[i], []string{"string"}, name, position)
+			}
+			state.Push("string")
+		case "repeat":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			checker.Expect(a[1], []string{"int"}, name, position)
+			state.Push("string")
+		case "format":
+			// the number of elements is in the format string
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			state.SetUnknown()
+			state.Push("string")
+		case "system":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+		case "fopen", "fopen-read", "fopen-append":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			state.Push("file")
+		case "fclose", "ftruncate":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"file"}, name, position)
+		case "fwrite":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"string"}, name, position)
+			checker.Expect(a[1], []string{"file"}, name, position)
+		case "fread":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"file"}, name, position)
+			state.Push("string")
+		case "errmsg":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"error"}, name, position)
+			state.Push("string")
+		case "assert-equal", "assert-not-equal":
+			checker.Pop(state, 2, name, position)
+		case "assert-depth":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"int"}, name, position)
+		case "assert-raises":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"quote"}, name, position)
+			checker.Expect(a[1], []string{"error", "type"}, name, position)
+		case "get":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"map"}, name, position)
+			state.Push("any")
+		case "set":
+			a := checker.Pop(state, 3, name, position)
+			checker.Expect(a[0], []string{"map"}, name, position)
+			state.Push("map")
+		case "delete":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"map"}, name, position)
+			state.Push("map")
+		case "has-key":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"map"}, name, position)
+			state.Push("bool")
+		case "keys", "values":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"map"}, name, position)
+			state.Push("list")
+		case "call":
+			a := checker.Pop(state, 1, name, position)
+			checker.Expect(a[0], []string{"quote"}, name, position)
+			state.SetUnknown()
+		case "collect", "filter":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"list"}, name, position)
+			checker.Expect(a[1], []string{"quote"}, name, position)
+			state.Push("list")
+		case "each":
+			a := checker.Pop(state, 2, name, position)
+			checker.Expect(a[0], []string{"list"}, name, position)
+			checker.Expect(a[1], []string{"quote"}, name, position)
+			state.SetUnknown()
+		case "reduce":
+			a := checker.Pop(state, 3, name, position)
+			checker.Expect(a[0], []string{"list"}, name, position)
+			checker.Expect(a[2], []string{"quote"}, name, position)
+			state.SetUnknown()
+		default:
+			state.SetUnknown()
+	}
+}
@@ -0,0 +1,32 @@
+package tsharp
+
+import (
+	"testing"
+)
+
+// An error that a try statement catches is not reported.
+func TestCheckTry(t *testing.T) {
+	tests := []struct {
+		source string
+		errors []ErrorType
+	}{
+		{"try drop except error do 1 end 1 +", nil},
+		{"try drop except StackUnderflowError -> e do 1 end", nil},
+		{"try 1 \"a\" + except TypeError do 2 end", nil},
+		{"try drop except TypeError do 1 end", []ErrorType{StackUnderflowError}},
+		{"try drop finally 1 end", []ErrorType{StackUnderflowError}},
+		{"try drop except error do 1 \"a\" + end", []ErrorType{TypeError}},
+	}
+	for _, test := range tests {
+		errors := Check(ParseTestSource(t, test.source))
+		if len(errors) != len(test.errors) {
+			t.Errorf("%q: got %v, want %v", test.source, errors, test.errors)
+			continue
+		}
+		for i := 0; i < len(errors); i++ {
+			if errors[i].Type != test.errors[i] {
+				t.Errorf("%q: got %v, want %v", test.source, errors, test.errors)
+			}
+		}
+	}
+}
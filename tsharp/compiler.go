@@ -0,0 +1,460 @@
+package tsharp
+
+
+// -----------------------------
+// --------- Compiler ----------
+// -----------------------------
+
+type Opcode uint8
+const (
+	OP_PUSH Opcode = iota // A: constant
+	OP_LIST_BEGIN
+	OP_LIST_END
+	OP_MAP_END
+	OP_VAR // A: global slot, B: ref or -1
+	OP_TAIL_VAR // A: global slot, B: ref or -1
+	OP_VARDEF // A: global slot, B: local slot or -1
+	OP_ASSIGN // A: global slot, B: ref or -1
+	OP_CLOSURE // A: constant (Blockdef or AsQuote)
+	OP_WORD // A: word, B: constant (AsId)
+	OP_CALL // A: constant (AsId), B: 1 in tail position
+	OP_BINOP // A: operator
+	OP_COMPARE // A: operator
+	OP_BLOCKDEF // A: constant (Blockdef)
+	OP_ERRORDEF // A: constant (Errordef), pushes its error type
+	OP_INCLUDE // A: constant (Include)
+	OP_IMPORT // A: constant (Import)
+	OP_MEMBER // A: member
+	OP_ASSERT // A: constant (Assert)
+	OP_JUMP // A: target
+	OP_JUMP_FALSE // A: target, B: CondIf or CondFor
+	OP_TRY // A: target of the except clauses
+	OP_END_TRY // B: 1 when a `break` leaves the try statement
+	OP_CATCH // A: catch
+	OP_END_FINALLY // A: target of a `break`, B: 1 when the finally body breaks
+	OP_RETURN
+)
+
+const (
+	CondIf = iota
+	CondFor
+)
+
+type Instruction struct {
+	Op Opcode
+	A int
+	B int
+}
+
+// Catch holds the `except` clauses of a try statement.
+type Catch struct {
+	Errors []AST
+	ErrorSlots [][2]int
+	Names []string
+	NameSlots [][2]int
+	Targets []int
+	Finally int
+}
+
+// Ref lists the local slots a name can be found in, from the innermost
+// block out, as {depth, slot} pairs: depth counts the Outer links from the
+// Env of the frame.
+type Ref [][2]int
+
+// Member is a `module.name` read: the slots of the module variable, and
+// the Var.
+type Member struct {
+	Global int
+	Ref int
+	Var Var
+}
+
+// Chunk is a compiled block, file or program. Positions holds the source
+// position of each instruction, and Locals the names of the local slots.
+type Chunk struct {
+	Code []Instruction
+	Positions []NodePosition
+	Constants []AST
+	Words []Word
+	Catches []Catch
+	Refs []Ref
+	Members []Member
+	Locals []string
+	Owner int
+}
+
+// Compiler compiles one chunk. outer is the compiler of the block the
+// chunk is written in, whose locals the chunk can see.
+type Compiler struct {
+	vm *VM
+	chunk *Chunk
+	locals map[string]int
+	outer *Compiler
+	breaks [][]int
+}
+
+// CollectLocals finds the names a block body defines with `->`, `block`
+// or `exception`. They live in the slots of the block's frame, the other names
+// belong to the blocks around it or are global.
+func CollectLocals(node AST, locals map[string]int, names *[]string) {
+	if node == nil {
+		return
+	}
+	add := func(name string) {
+		if _, ok := locals[name]; !ok {
+			locals[name] = len(*names)
+			*names = append(*names, name)
+		}
+	}
+	switch node.(type) {
+		case AsStatements:
+			for i := 0; i < len(node.(AsStatements)); i++ {
+				CollectLocals(node.(AsStatements)[i], locals, names)
+			}
+		case AsPush:
+			switch node.(AsPush).value.(type) {
+				case NewList: CollectLocals(node.(AsPush).value.(NewList).ListBody, locals, names)
+				case NewMap: CollectLocals(node.(AsPush).value.(NewMap).MapBody, locals, names)
+			}
+		case Vardef:
+			if !node.(Vardef).Outer {
+				add(node.(Vardef).Name)
+			}
+		case Blockdef:
+			add(node.(Blockdef).Name)
+		case Errordef:
+			add(node.(Errordef).Name)
+		case Import:
+			add(node.(Import).Name)
+		case If:
+			CollectLocals(node.(If).IfOp, locals, names)
+			CollectLocals(node.(If).IfBody, locals, names)
+			for i := 0; i < len(node.(If).ElifOps); i++ {
+				CollectLocals(node.(If).ElifOps[i], locals, names)
+				CollectLocals(node.(If).ElifBodys[i], locals, names)
+			}
+			CollectLocals(node.(If).ElseBody, locals, names)
+		case For:
+			CollectLocals(node.(For).ForOp, locals, names)
+			CollectLocals(node.(For).ForBody, locals, names)
+		case Try:
+			CollectLocals(node.(Try).TryBody, locals, names)
+			for i := 0; i < len(node.(Try).ExceptBodys); i++ {
+				if node.(Try).ExceptNames[i] != "" {
+					add(node.(Try).ExceptNames[i])
+				}
+				CollectLocals(node.(Try).ExceptBodys[i], locals, names)
+			}
+			CollectLocals(node.(Try).FinallyBody, locals, names)
+	}
+}
+
+// Compile compiles a program or an included file. Every name in it is
+// global.
+func (vm *VM) Compile(ast AST) *Chunk {
+	compiler := &Compiler{vm: vm, chunk: &Chunk{Owner: vm.Id}}
+	compiler.CompileBody(ast)
+	return compiler.chunk
+}
+
+// CompileModule compiles the file of a module. Its top-level names are
+// locals of the module's frame, where its blocks find them.
+func (vm *VM) CompileModule(ast AST) *Chunk {
+	compiler := &Compiler{vm: vm, chunk: &Chunk{Owner: vm.Id}, locals: map[string]int{}}
+	CollectLocals(ast, compiler.locals, &compiler.chunk.Locals)
+	compiler.CompileBody(ast)
+	return compiler.chunk
+}
+
+func (vm *VM) CompileBlock(block Blockdef, outer *Compiler) *Chunk {
+	if outer != nil && outer.locals == nil {
+		outer = nil
+	}
+	compiler := &Compiler{vm: vm, chunk: &Chunk{Owner: vm.Id}, locals: map[string]int{}, outer: outer}
+	CollectLocals(block.BlockBody, compiler.locals, &compiler.chunk.Locals)
+	compiler.CompileBody(block.BlockBody)
+	return compiler.chunk
+}
+
+// CompileBody compiles statements that end with OP_RETURN. A `break`
+// outside a loop leaves the block, like it does in VisitorVisit.
+func (compiler *Compiler) CompileBody(node AST) {
+	compiler.BeginBreaks()
+	compiler.CompileStatements(node)
+	compiler.EndBreaks()
+	compiler.Emit(OP_RETURN, 0, 0, NodePosition{})
+}
+
+func (compiler *Compiler) Emit(op Opcode, a int, b int, position NodePosition) int {
+	compiler.chunk.Code = append(compiler.chunk.Code, Instruction{op, a, b})
+	compiler.chunk.Positions = append(compiler.chunk.Positions, position)
+	return len(compiler.chunk.Code)-1
+}
+
+func (compiler *Compiler) Constant(node AST) int {
+	compiler.chunk.Constants = append(compiler.chunk.Constants, node)
+	return len(compiler.chunk.Constants)-1
+}
+
+func (compiler *Compiler) Patch(at int) {
+	compiler.chunk.Code[at].A = len(compiler.chunk.Code)
+}
+
+// BeginBreaks and EndBreaks mark the end of a loop, a list or a try part:
+// the `break`s between them jump to where EndBreaks is called.
+func (compiler *Compiler) BeginBreaks() {
+	compiler.breaks = append(compiler.breaks, []int{})
+}
+
+func (compiler *Compiler) EndBreaks() {
+	jumps := compiler.PopBreaks()
+	for i := 0; i < len(jumps); i++ {
+		compiler.Patch(jumps[i])
+	}
+}
+
+// PopBreaks ends the breaks like EndBreaks, but returns the jumps for the
+// caller to patch.
+func (compiler *Compiler) PopBreaks() []int {
+	jumps := compiler.breaks[len(compiler.breaks)-1]
+	compiler.breaks = compiler.breaks[:len(compiler.breaks)-1]
+	return jumps
+}
+
+// Break adds the jump at to the breaks of the innermost loop.
+func (compiler *Compiler) Break(at int) {
+	compiler.breaks[len(compiler.breaks)-1] = append(compiler.breaks[len(compiler.breaks)-1], at)
+}
+
+// Slots resolves a name that is read, or assigned with `=>`, to its
+// global slot and a ref.
+func (compiler *Compiler) Slots(name string) (int, int) {
+	var ref Ref
+	depth := 0
+	for c := compiler; c != nil && c.locals != nil; c = c.outer {
+		if slot, ok := c.locals[name]; ok {
+			ref = append(ref, [2]int{depth, slot})
+		}
+		depth++
+	}
+	if ref == nil {
+		return compiler.vm.Slot(name), -1
+	}
+	compiler.chunk.Refs = append(compiler.chunk.Refs, ref)
+	return compiler.vm.Slot(name), len(compiler.chunk.Refs)-1
+}
+
+// Local resolves a name defined with `->` to its global slot and its
+// slot in the frame, or -1 outside a block.
+func (compiler *Compiler) Local(name string) (int, int) {
+	local := -1
+	if compiler.locals != nil {
+		if slot, ok := compiler.locals[name]; ok {
+			local = slot
+		}
+	}
+	return compiler.vm.Slot(name), local
+}
+
+func (compiler *Compiler) CompileStatements(node AST) {
+	if node == nil {
+		return
+	}
+	for i := 0; i < len(node.(AsStatements)); i++ {
+		compiler.CompileNode(node.(AsStatements)[i])
+	}
+}
+
+func (compiler *Compiler) CompileNode(node AST) {
+	switch node.(type) {
+		case AsPush:
+			value := node.(AsPush).value
+			switch value.(type) {
+				case NewList:
+					compiler.Emit(OP_LIST_BEGIN, 0, 0, NodePosition{})
+					compiler.BeginBreaks()
+					compiler.CompileStatements(value.(NewList).ListBody)
+					compiler.EndBreaks()
+					compiler.Emit(OP_LIST_END, 0, 0, NodePosition{})
+				case NewMap:
+					compiler.Emit(OP_LIST_BEGIN, 0, 0, NodePosition{})
+					compiler.BeginBreaks()
+					compiler.CompileStatements(value.(NewMap).MapBody)
+					compiler.EndBreaks()
+					compiler.Emit(OP_MAP_END, 0, 0, value.(NewMap).Position)
+				case Var:
+					global, local := compiler.Slots(value.(Var).Name)
+					if value.(Var).Member != "" {
+						compiler.chunk.Members = append(compiler.chunk.Members, Member{global, local, value.(Var)})
+						compiler.Emit(OP_MEMBER, len(compiler.chunk.Members)-1, 0, value.(Var).Position)
+					} else if value.(Var).Tail {
+						compiler.Emit(OP_TAIL_VAR, global, local, value.(Var).Position)
+					} else {
+						compiler.Emit(OP_VAR, global, local, value.(Var).Position)
+					}
+				case AsQuote:
+					quote := value.(AsQuote)
+					quote.Block.Code = compiler.vm.CompileBlock(quote.Block, compiler)
+					compiler.Emit(OP_CLOSURE, compiler.Constant(quote), 0, NodePosition{})
+				default:
+					compiler.Emit(OP_PUSH, compiler.Constant(value), 0, NodePosition{})
+			}
+		case AsId:
+			if node.(AsId).name == "break" {
+				compiler.Break(compiler.Emit(OP_JUMP, 0, 0, node.(AsId).Position))
+				return
+			}
+			if node.(AsId).name == "call" {
+				tail := 0
+				if node.(AsId).Tail {
+					tail = 1
+				}
+				compiler.Emit(OP_CALL, compiler.Constant(node), tail, node.(AsId).Position)
+				return
+			}
+			compiler.chunk.Words = append(compiler.chunk.Words, Words[node.(AsId).name])
+			compiler.Emit(OP_WORD, len(compiler.chunk.Words)-1, compiler.Constant(node), node.(AsId).Position)
+		case AsBinop:
+			compiler.Emit(OP_BINOP, int(node.(AsBinop).op), 0, node.(AsBinop).Position)
+		case Compare:
+			compiler.Emit(OP_COMPARE, int(node.(Compare).op), 0, node.(Compare).Position)
+		case Vardef:
+			if node.(Vardef).Outer {
+				global, ref := compiler.Slots(node.(Vardef).Name)
+				compiler.Emit(OP_ASSIGN, global, ref, node.(Vardef).Position)
+				return
+			}
+			global, local := compiler.Local(node.(Vardef).Name)
+			compiler.Emit(OP_VARDEF, global, local, node.(Vardef).Position)
+		case Blockdef:
+			block := node.(Blockdef)
+			block.Code = compiler.vm.CompileBlock(block, compiler)
+			if compiler.locals == nil {
+				compiler.Emit(OP_BLOCKDEF, compiler.Constant(block), compiler.vm.Slot(block.Name), block.Position)
+				return
+			}
+			global, local := compiler.Local(block.Name)
+			compiler.Emit(OP_CLOSURE, compiler.Constant(block), 0, block.Position)
+			compiler.Emit(OP_VARDEF, global, local, block.Position)
+		case Errordef:
+			global, local := compiler.Local(node.(Errordef).Name)
+			compiler.Emit(OP_ERRORDEF, compiler.Constant(node), 0, node.(Errordef).Position)
+			compiler.Emit(OP_VARDEF, global, local, node.(Errordef).Position)
+		case Include:
+			compiler.Emit(OP_INCLUDE, compiler.Constant(node), 0, node.(Include).Position)
+		case Import:
+			compiler.Emit(OP_IMPORT, compiler.Constant(node), 0, node.(Import).Position)
+			global, local := compiler.Local(node.(Import).Name)
+			compiler.Emit(OP_VARDEF, global, local, node.(Import).Position)
+		case Assert:
+			compiler.Emit(OP_ASSERT, compiler.Constant(node), 0, node.(Assert).Position)
+		case AsStatements:
+			compiler.CompileStatements(node)
+		case If:
+			compiler.CompileIf(node.(If))
+		case For:
+			compiler.CompileFor(node.(For))
+		case Try:
+			compiler.CompileTry(node.(Try))
+		default:
+			panic("unreachable")
+	}
+}
+
+func (compiler *Compiler) CompileIf(node If) {
+	var ends []int
+	compiler.CompileStatements(node.IfOp)
+	next := compiler.Emit(OP_JUMP_FALSE, 0, CondIf, node.Position)
+	compiler.CompileStatements(node.IfBody)
+	ends = append(ends, compiler.Emit(OP_JUMP, 0, 0, node.Position))
+	compiler.Patch(next)
+	for i := 0; i < len(node.ElifOps); i++ {
+		compiler.CompileStatements(node.ElifOps[i])
+		next := compiler.Emit(OP_JUMP_FALSE, 0, CondIf, node.ElifPositions[i])
+		compiler.CompileStatements(node.ElifBodys[i])
+		ends = append(ends, compiler.Emit(OP_JUMP, 0, 0, node.ElifPositions[i]))
+		compiler.Patch(next)
+	}
+	compiler.CompileStatements(node.ElseBody)
+	for i := 0; i < len(ends); i++ {
+		compiler.Patch(ends[i])
+	}
+}
+
+func (compiler *Compiler) CompileFor(node For) {
+	loop := len(compiler.chunk.Code)
+	compiler.BeginBreaks()
+	compiler.CompileStatements(node.ForOp)
+	end := compiler.Emit(OP_JUMP_FALSE, 0, CondFor, node.Position)
+	compiler.CompileStatements(node.ForBody)
+	compiler.Emit(OP_JUMP, loop, 0, node.Position)
+	compiler.Patch(end)
+	compiler.EndBreaks()
+}
+
+// CompileTry lays out a try statement as
+//
+//	OP_TRY except; body; OP_END_TRY; OP_JUMP finally
+//	except: OP_CATCH; handler; OP_END_TRY; OP_JUMP finally; ...
+//	break: OP_END_TRY 1; OP_JUMP finally
+//	finally: body; OP_END_FINALLY
+//
+// An error in the body or in a handler reaches the finally body with the
+// error pending, and OP_END_FINALLY raises it again. A `break` in them
+// goes to break, and is pending the same way: OP_END_FINALLY jumps out of
+// the loop after the finally body. The `break`s of the finally body go to
+// an OP_END_FINALLY of their own, after the first.
+func (compiler *Compiler) CompileTry(node Try) {
+	var finally []int
+	try := compiler.Emit(OP_TRY, 0, 0, node.Position)
+	compiler.BeginBreaks()
+	compiler.CompileStatements(node.TryBody)
+	compiler.Emit(OP_END_TRY, 0, 0, node.Position)
+	finally = append(finally, compiler.Emit(OP_JUMP, 0, 0, node.Position))
+	compiler.Patch(try)
+	catch := Catch{Errors: node.ExceptErrors, Names: node.ExceptNames}
+	for i := 0; i < len(node.ExceptErrors); i++ {
+		var slots [2]int
+		if _, ok := node.ExceptErrors[i].(Var); ok {
+			slots[0], slots[1] = compiler.Slots(node.ExceptErrors[i].(Var).Name)
+		}
+		catch.ErrorSlots = append(catch.ErrorSlots, slots)
+		slots = [2]int{-1, -1}
+		if node.ExceptNames[i] != "" {
+			slots[0], slots[1] = compiler.Local(node.ExceptNames[i])
+		}
+		catch.NameSlots = append(catch.NameSlots, slots)
+	}
+	index := len(compiler.chunk.Catches)
+	compiler.chunk.Catches = append(compiler.chunk.Catches, catch)
+	compiler.Emit(OP_CATCH, index, 0, node.Position)
+	for i := 0; i < len(node.ExceptBodys); i++ {
+		compiler.chunk.Catches[index].Targets = append(compiler.chunk.Catches[index].Targets, len(compiler.chunk.Code))
+		compiler.CompileStatements(node.ExceptBodys[i])
+		compiler.Emit(OP_END_TRY, 0, 0, node.Position)
+		finally = append(finally, compiler.Emit(OP_JUMP, 0, 0, node.Position))
+	}
+	if breaks := compiler.PopBreaks(); len(breaks) > 0 {
+		for i := 0; i < len(breaks); i++ {
+			compiler.Patch(breaks[i])
+		}
+		compiler.Emit(OP_END_TRY, 0, 1, node.Position)
+		finally = append(finally, compiler.Emit(OP_JUMP, 0, 0, node.Position))
+	}
+	compiler.chunk.Catches[index].Finally = len(compiler.chunk.Code)
+	for i := 0; i < len(finally); i++ {
+		compiler.Patch(finally[i])
+	}
+	compiler.BeginBreaks()
+	compiler.CompileStatements(node.FinallyBody)
+	breaks := compiler.PopBreaks()
+	compiler.Break(compiler.Emit(OP_END_FINALLY, 0, 0, node.Position))
+	if len(breaks) > 0 {
+		end := compiler.Emit(OP_JUMP, 0, 0, node.Position)
+		for i := 0; i < len(breaks); i++ {
+			compiler.Patch(breaks[i])
+		}
+		compiler.Break(compiler.Emit(OP_END_FINALLY, 0, 1, node.Position))
+		compiler.Patch(end)
+	}
+}
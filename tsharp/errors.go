@@ -0,0 +1,186 @@
+package tsharp
+
+import (
+	"fmt"
+	"strings"
+)
+
+
+// -----------------------------
+// ---------- Errors -----------
+// -----------------------------
+
+type ErrorType int
+const (
+	ErrorVoid ErrorType = iota
+	StackUnderflowError
+	NameError
+	TypeError
+	IndexError
+	IncludeError
+	AssertionError
+	FileNotFoundError
+	CommandError
+	SyntaxError
+	RecursionError
+	ImportError
+	ZeroDivisionError
+	ValueError
+	ExitSignal
+)
+
+var ErrorNames = []string{
+	StackUnderflowError: "StackUnderflowError",
+	NameError:           "NameError",
+	TypeError:           "TypeError",
+	IndexError:          "IndexError",
+	IncludeError:        "IncludeError",
+	AssertionError:      "AssertionError",
+	FileNotFoundError:   "FileNotFoundError",
+	CommandError:        "CommandError",
+	SyntaxError:         "SyntaxError",
+	RecursionError:      "RecursionError",
+	ImportError:         "ImportError",
+	ZeroDivisionError:   "ZeroDivisionError",
+	ValueError:          "ValueError",
+	ExitSignal:          "",
+}
+
+var BuiltinErrorCount = len(ErrorNames)
+
+func (Type ErrorType) String() string {
+	if int(Type) > 0 && int(Type) < len(ErrorNames) && ErrorNames[Type] != "" {
+		return ErrorNames[Type]
+	}
+	return fmt.Sprintf("unexpected error <%d>", int(Type))
+}
+
+func IsBuiltinError(name string) bool {
+	_, ok := LookupErrorType(name)
+	return ok
+}
+
+// LookupErrorType finds a built-in error type by name. The types
+// declared with `exception` belong to an interpreter, in Scope.Errors.
+func LookupErrorType(name string) (ErrorType, bool) {
+	for i := 1; i < BuiltinErrorCount; i++ {
+		if ErrorNames[i] != "" && ErrorNames[i] == name {
+			return ErrorType(i), true
+		}
+	}
+	return ErrorVoid, false
+}
+
+type Error struct {
+    message string
+	Type ErrorType
+	Name string
+	Position NodePosition
+	Code int
+	Trace []CallSite
+	Stack []AST
+}
+
+// CallSite is a running block call: the name of the block and where it
+// was called from.
+type CallSite struct {
+	Name string
+	Position NodePosition
+}
+
+// Exit status of `tsh` for each kind of uncaught error.
+// `exit` uses the <int> value on top of the stack instead, from 0 to 255.
+var ExitCodes = map[ErrorType]int{
+	SyntaxError:         2,
+	AssertionError:      3,
+	StackUnderflowError: 4,
+	NameError:           5,
+	TypeError:           6,
+	IndexError:          7,
+	IncludeError:        8,
+	FileNotFoundError:   9,
+	CommandError:        10,
+	RecursionError:      11,
+	ImportError:         12,
+	ZeroDivisionError:   13,
+	ValueError:          14,
+}
+
+func (err *Error) ExitCode() int {
+	if err.Type == ExitSignal {
+		return err.Code
+	}
+	if code, ok := ExitCodes[err.Type]; ok {
+		return code
+	}
+	return 1
+}
+
+func (err *Error) Error() string {
+	return err.message
+}
+
+// Text is the message without the leading `file:Type:line:column: `.
+// TypeName is the name of the type of the error, which is Name for the
+// types declared with `exception`.
+func (err *Error) TypeName() string {
+	if err.Name != "" {
+		return err.Name
+	}
+	return err.Type.String()
+}
+
+func (err *Error) Text() string {
+	text := strings.TrimPrefix(err.message, err.Position.FileName+":")
+	if index := strings.Index(text, fmt.Sprintf(":%d:%d: ", err.Position.Line, err.Position.Column)); index >= 0 {
+		return text[index+len(fmt.Sprintf(":%d:%d: ", err.Position.Line, err.Position.Column)):]
+	}
+	return err.message
+}
+
+// SetTrace records the block calls that are running and the stack where
+// an error happened. Only the first call counts: Stack stays nil until
+// then.
+func (err *Error) SetTrace(trace []CallSite, stack []AST) {
+	if err.Stack != nil {
+		return
+	}
+	err.Trace = append([]CallSite{}, trace...)
+	err.Stack = append([]AST{}, stack...)
+}
+
+// Report is what `tsh` prints for an uncaught error: the message, the
+// block calls that led to it and the stack where it happened.
+func (err *Error) Report() string {
+	lines := []string{err.message}
+	if trace := err.TraceString(); trace != "" {
+		lines = append(lines, trace)
+	}
+	if err.Stack != nil {
+		lines = append(lines, "stack: "+FormatStack(err.Stack))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// TraceString formats the block calls that were running when the error
+// happened, the most recent last. A call repeated many times in a row is
+// shown once with the count.
+func (err *Error) TraceString() string {
+	if len(err.Trace) == 0 {
+		return ""
+	}
+	lines := []string{"T# call trace (most recent call last):"}
+	for i := 0; i < len(err.Trace); {
+		call := err.Trace[i]
+		lines = append(lines, fmt.Sprintf("  block `%s` called at %s:%d:%d", call.Name, call.Position.FileName, call.Position.Line, call.Position.Column))
+		n := 1
+		for i+n < len(err.Trace) && err.Trace[i+n] == call {
+			n++
+		}
+		if n > 1 {
+			lines = append(lines, fmt.Sprintf("  [previous call repeated %d more times]", n-1))
+		}
+		i += n
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,200 @@
+package tsharp
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+
+// -----------------------------
+// --------- Formatter ---------
+// -----------------------------
+
+const FormatIndent = "    "
+
+// FormatLine is a line of formatted code: the tokens of a line of the
+// source, and the comment that ends it.
+type FormatLine struct {
+	Depth int
+	Tokens []string
+	Comment string
+	Blank bool
+}
+
+func (line FormatLine) Code() string {
+	return strings.Repeat(FormatIndent, line.Depth) + strings.Join(line.Tokens, " ")
+}
+
+// FormatSource formats T# code. The code is parsed first, so only a valid
+// program is formatted; then it is formatted from its tokens, so the line
+// breaks and the comments stay where they are. Each line is indented by
+// the bodies it is in, a line that closes bodies by the outermost of
+// them, its tokens are separated by one space, with the string literals
+// as they are written, the comments that end
+// lines next to each other are aligned, and blank lines are kept, one
+// at most.
+func FormatSource(source string, FileName string) (string, *Error) {
+	parser, err := ParserInit(LexerInit(strings.NewReader(source), FileName))
+	if err != nil {
+		return "", err
+	}
+	if _, err := ParserParse(parser); err != nil {
+		return "", err
+	}
+	if parser.current_token_type != TOKEN_EOF {
+		return "", UnexpectedTokenError(parser)
+	}
+	SourceLines := strings.Split(source, "\n")
+	lexer := LexerInit(strings.NewReader(source), FileName)
+	lexer.KeepComments = true
+	lines := []FormatLine{}
+	depth := 0
+	last := 0
+	for {
+		pos, tok, val, _, err := lexer.Lex()
+		if err != nil {
+			return "", err
+		}
+		if tok == TOKEN_EOF {
+			break
+		}
+		if len(lines) == 0 || pos.line > last {
+			if len(lines) != 0 && pos.line > last+1 {
+				lines = append(lines, FormatLine{Blank: true})
+			}
+			lines = append(lines, FormatLine{Depth: depth})
+		}
+		line := &lines[len(lines)-1]
+		last = lexer.pos.line
+		switch tok {
+			case TOKEN_COMMENT:
+				line.Comment = strings.TrimRightFunc(val, unicode.IsSpace)
+				last = pos.line
+			case TOKEN_STRING:
+				literal, ok := SourceText(SourceLines, pos, lexer.pos)
+				if !ok {
+					err := Error{}
+					err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: cannot find the string literal in the source.", FileName, pos.line, pos.column)
+					err.Type = SyntaxError
+					err.Position = NodePosition{FileName, pos.line, pos.column}
+					return "", &err
+				}
+				line.Tokens = append(line.Tokens, literal)
+			default: line.Tokens = append(line.Tokens, val)
+		}
+		// a line is indented as the outermost body it closes
+		switch tok {
+			case TOKEN_DO, TOKEN_L_BRACKET, TOKEN_L_SQUARE: depth++
+			case TOKEN_END, TOKEN_R_BRACKET, TOKEN_R_SQUARE, TOKEN_ELIF, TOKEN_EXCEPT:
+				depth--
+				if depth < line.Depth {
+					line.Depth = depth
+				}
+			case TOKEN_ELSE, TOKEN_FINALLY:
+				if depth-1 < line.Depth {
+					line.Depth = depth-1
+				}
+			case TOKEN_ID:
+				if val == "try" {
+					depth++
+				}
+		}
+	}
+	return FormatLines(lines), nil
+}
+
+// SourceText is the text of the source from the column of start to the
+// column of end, both included, as the lexer counts them: the literal of
+// a string, with its quotes. ok is false when it is not a literal.
+func SourceText(lines []string, start Position, end Position) (string, bool) {
+	if start.line < 1 || end.line > len(lines) || end.line < start.line {
+		return "", false
+	}
+	var text []string
+	for i := start.line; i <= end.line; i++ {
+		runes := []rune(lines[i-1])
+		from, to := 0, len(runes)
+		if i == start.line {
+			from = start.column-1
+		}
+		if i == end.line {
+			to = end.column
+		}
+		if from < 0 || to > len(runes) || from > to {
+			return "", false
+		}
+		text = append(text, string(runes[from:to]))
+	}
+	literal := strings.Join(text, "\n")
+	if len(literal) < 2 || literal[0] != literal[len(literal)-1] || (literal[0] != '"' && literal[0] != '\'') {
+		return "", false
+	}
+	return literal, true
+}
+
+// FormatLines writes the lines. The comments of lines of code next to
+// each other start in the same column.
+func FormatLines(lines []FormatLine) string {
+	var out strings.Builder
+	for i := 0; i < len(lines); i++ {
+		if lines[i].Blank {
+			out.WriteString("\n")
+			continue
+		}
+		code := lines[i].Code()
+		if lines[i].Comment == "" {
+			out.WriteString(code + "\n")
+			continue
+		}
+		if len(lines[i].Tokens) == 0 {
+			out.WriteString(code + lines[i].Comment + "\n")
+			continue
+		}
+		width := 0
+		for j := i; j >= 0 && HasCodeComment(lines[j]); j-- {
+			if n := CodeWidth(lines[j].Code()); n > width {
+				width = n
+			}
+		}
+		for j := i; j < len(lines) && HasCodeComment(lines[j]); j++ {
+			if n := CodeWidth(lines[j].Code()); n > width {
+				width = n
+			}
+		}
+		padding := strings.Repeat(" ", width-CodeWidth(code)+1)
+		out.WriteString(code + padding + lines[i].Comment + "\n")
+	}
+	return out.String()
+}
+
+// CodeWidth is the width of the last line of code, which is the line of
+// the comment when a string in it spans lines.
+func CodeWidth(code string) int {
+	return utf8.RuneCountInString(code[strings.LastIndex(code, "\n")+1:])
+}
+
+// HasCodeComment reports whether the line has code and a comment after it.
+func HasCodeComment(line FormatLine) bool {
+	return !line.Blank && len(line.Tokens) != 0 && line.Comment != ""
+}
+
+// FormatFile formats a file, and reports whether the formatted code is
+// different from the file.
+func FormatFile(FileName string) (string, bool, *Error) {
+	source, ReadErr := os.ReadFile(FileName)
+	if ReadErr != nil {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:FileNotFoundError:0:0: cannot open file `%s`.", FileName, FileName)
+		err.Type = FileNotFoundError
+		err.Position = NodePosition{FileName, 0, 0}
+		return "", false, &err
+	}
+	formatted, err := FormatSource(string(source), FileName)
+	if err != nil {
+		return "", false, err
+	}
+	return formatted, formatted != string(source), nil
+}
@@ -0,0 +1,90 @@
+package tsharp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestFormatSource(t *testing.T) {
+	tests := []struct {
+		name string
+		source string
+		formatted string
+	}{
+		{
+			"indentation",
+			"block f do\n\t1 2   +\n  if dup 0 > do\n\"pos\" println\n  elif dup 0 == do\n  \"zero\" println\n        else\n\"neg\" println\nend\nend\n",
+			"block f do\n    1 2 +\n    if dup 0 > do\n        \"pos\" println\n    elif dup 0 == do\n        \"zero\" println\n    else\n        \"neg\" println\n    end\nend\n",
+		},
+		{
+			"try and lists",
+			"try\ndrop\nexcept StackUnderflowError -> e do\n{ 1\n2 }\nfinally\n[ \"a\" 1\n]\nend\n",
+			"try\n    drop\nexcept StackUnderflowError -> e do\n    { 1\n    2 }\nfinally\n    [ \"a\" 1\n    ]\nend\n",
+		},
+		{
+			"comments are aligned",
+			"1 2 + # add\ndup dup * * # cube\n# a line of its own\n1 # one\n\n\"a\" # after a blank line\n",
+			"1 2 +       # add\ndup dup * * # cube\n# a line of its own\n1 # one\n\n\"a\" # after a blank line\n",
+		},
+		{
+			"blank lines are collapsed",
+			"1\n\n\n\n2\n\n",
+			"1\n\n2\n",
+		},
+		{
+			"strings are kept as written",
+			"\"a\\tb\"   'c d' println\n'say \"hi\"'  \"\\u00e9\" println\n",
+			"\"a\\tb\" 'c d' println\n'say \"hi\"' \"\\u00e9\" println\n",
+		},
+		{
+			"multi-line strings",
+			"block f do\n\"a\n  b\"   println # c\n1 # d\nend\n'x\ny'\n2\n",
+			"block f do\n    \"a\n  b\" println # c\n    1        # d\nend\n'x\ny'\n2\n",
+		},
+		{"empty", "", ""},
+	}
+	for _, test := range tests {
+		formatted, err := FormatSource(test.source, "test.tsp")
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", test.name, err)
+			continue
+		}
+		if formatted != test.formatted {
+			t.Errorf("%s: got\n%s\nwant\n%s", test.name, formatted, test.formatted)
+		}
+		if again, err := FormatSource(formatted, "test.tsp"); err != nil || again != formatted {
+			t.Errorf("%s: formatting again gives\n%s\n%v", test.name, again, err)
+		}
+	}
+}
+
+func TestFormatSourceSyntaxError(t *testing.T) {
+	for _, source := range []string{"block f do", "if 1 do 2", "end", "\"a\\qb\" println", "'a\\qb'"} {
+		if _, err := FormatSource(source, "test.tsp"); err == nil || err.Type != SyntaxError {
+			t.Errorf("%q: got %v, want a SyntaxError", source, err)
+		}
+	}
+}
+
+// The files of the repository are formatted, and stay as they are.
+func TestFormatSourceIdempotent(t *testing.T) {
+	files, err := FindFiles([]string{"../examples", "../test", "stdlib"}, ".tsp")
+	if err != nil || len(files) == 0 {
+		t.Fatalf("got %v, %v", files, err)
+	}
+	for _, file := range files {
+		source, ReadErr := os.ReadFile(file)
+		if ReadErr != nil {
+			t.Fatal(ReadErr)
+		}
+		formatted, err := FormatSource(string(source), filepath.Base(file))
+		if err != nil {
+			t.Errorf("%s: unexpected error %v", file, err)
+			continue
+		}
+		if again, _ := FormatSource(formatted, filepath.Base(file)); again != formatted {
+			t.Errorf("%s: formatting twice changes the file", file)
+		}
+	}
+}
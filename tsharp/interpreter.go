@@ -0,0 +1,113 @@
+package tsharp
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+
+// -----------------------------
+// -------- Interpreter --------
+// -----------------------------
+
+type Engine int
+const (
+	EngineVM Engine = iota
+	EngineTree
+)
+
+// Interpreter runs T# programs. The stack and the variables survive
+// between runs, so a host can feed it a program piece by piece.
+// Programs are compiled to bytecode for the VM unless Engine is
+// EngineTree, which walks the AST with VisitorVisit.
+type Interpreter struct {
+	Scope *Scope
+	Engine Engine
+	vm *VM
+}
+
+func NewInterpreter() *Interpreter {
+	scope := InitScope()
+	scope.OpAgrv([]string{})
+	return &Interpreter{
+		Scope: scope,
+		Engine: EngineVM,
+		vm: NewVM(scope),
+	}
+}
+
+// SetArgs sets the value of `argv`.
+func (interpreter *Interpreter) SetArgs(args []string) {
+	interpreter.Scope.OpAgrv(args)
+}
+
+// SetOutput sets where `print`, `println` and `system` write.
+func (interpreter *Interpreter) SetOutput(out io.Writer) {
+	interpreter.Scope.Out = out
+}
+
+// Stack returns a copy of the stack, which the interpreter keeps using.
+func (interpreter *Interpreter) Stack() []AST {
+	return append([]AST{}, interpreter.Scope.Stack...)
+}
+
+func (interpreter *Interpreter) Variables() map[string]AST {
+	return interpreter.Scope.Variables
+}
+
+func (interpreter *Interpreter) RunString(source string, FileName string) ([]AST, error) {
+	lexer := LexerInit(strings.NewReader(source), FileName)
+	parser, err := ParserInit(lexer)
+	if err != nil {
+		return interpreter.Stack(), err
+	}
+	ast, err := ParserParse(parser)
+	if err != nil {
+		return interpreter.Stack(), err
+	}
+	return interpreter.RunAST(ast)
+}
+
+func (interpreter *Interpreter) RunFile(FileName string) ([]AST, error) {
+	file, err := os.Open(FileName)
+	if err != nil {
+		return interpreter.Stack(), err
+	}
+	defer file.Close()
+	lexer := LexerInit(file, FileName)
+	parser, ParseErr := ParserInit(lexer)
+	if ParseErr != nil {
+		return interpreter.Stack(), ParseErr
+	}
+	ast, ParseErr := ParserParse(parser)
+	if ParseErr != nil {
+		return interpreter.Stack(), ParseErr
+	}
+	if interpreter.Scope.Modules.Root == "" {
+		interpreter.Scope.Modules.Root = filepath.Dir(FileName)
+	}
+	// the main file is loading too, so importing it is a cycle
+	if key, err := filepath.Abs(FileName); err == nil {
+		modules := interpreter.Scope.Modules
+		modules.Loading = append(modules.Loading, ModuleFile{key, FileName, ""})
+		defer func() { modules.Loading = modules.Loading[:len(modules.Loading)-1] }()
+	}
+	return interpreter.RunAST(ast)
+}
+
+func (interpreter *Interpreter) RunAST(ast AST) ([]AST, error) {
+	var err *Error
+	if interpreter.Engine == EngineTree {
+		interpreter.Scope.Call = interpreter.Scope.OpCall
+		_, err, _ = interpreter.Scope.VisitorVisit(ast, nil)
+	} else {
+		err = interpreter.vm.Execute(interpreter.vm.Compile(ast))
+	}
+	if err != nil {
+		err.SetTrace(nil, interpreter.Stack())
+		return interpreter.Stack(), err
+	}
+	return interpreter.Stack(), nil
+}
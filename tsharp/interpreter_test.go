@@ -0,0 +1,330 @@
+package tsharp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+var Engines = []struct {
+	Name string
+	Engine Engine
+}{
+	{"vm", EngineVM},
+	{"tree", EngineTree},
+}
+
+func NewTestInterpreter(engine Engine) *Interpreter {
+	interpreter := NewInterpreter()
+	interpreter.Engine = engine
+	return interpreter
+}
+
+func TestRunString(t *testing.T) {
+	tests := []struct {
+		source string
+		stack string
+	}{
+		{"1 2 +", "[3]"},
+		{"\"a\" \"b\" +", "[\"ab\"]"},
+		{"1.5 2 *", "[3.0]"},
+		{"{ 1 2 } len", "[2]"},
+		{"block sq do dup * end 4 sq", "[16]"},
+		{"9223372036854775807 1 +", "[9223372036854775808]"},
+		{"'say \"hi\"' 'it\\'s'", "[\"say \\\"hi\\\"\" \"it's\"]"},
+		{"\"a\nb\" 'c\nd'", "[\"a\\nb\" \"c\\nd\"]"},
+		{"{ 2 } { 2.0 } == { 1 2 } { 1 } ==", "[true false]"},
+		{"{ { 1 \"a\" } 2 } { { 1.0 \"a\" } 2 } ==", "[true]"},
+		{"{ 99999999999999999999 } { 99999999999999999999 } == { 99999999999999999999 } { 1 } ==", "[true false]"},
+		{"[ \"a\" { 2 } ] [ \"a\" { 2.0 } ] == [ \"a\" 2 ] [ \"a\" 3 ] ==", "[true false]"},
+		{"[ \"a\" 2 ] [ \"a\" 2.0 ] assert-equal { 1 { 2 } } { 1.0 { 2.0 } } assert-equal 1", "[1]"},
+		{"", "[]"},
+	}
+	for _, engine := range Engines {
+		for _, test := range tests {
+			stack, err := NewTestInterpreter(engine.Engine).RunString(test.source, "test.tsp")
+			if err != nil {
+				t.Errorf("%s: %q: unexpected error %v", engine.Name, test.source, err)
+				continue
+			}
+			if got := FormatStack(stack); got != test.stack {
+				t.Errorf("%s: %q: got %s, want %s", engine.Name, test.source, got, test.stack)
+			}
+		}
+	}
+}
+
+func TestRunStringErrors(t *testing.T) {
+	tests := []struct {
+		source string
+		Type ErrorType
+		code int
+	}{
+		{"drop", StackUnderflowError, 4},
+		{"nothing", NameError, 5},
+		{"1 \"a\" +", TypeError, 6},
+		{"\"x\" inc", TypeError, 6},
+		{"if 1 do 0 end", TypeError, 6},
+		{"{ 1 } 5 read", IndexError, 7},
+		{"{ 1 } 99999999999999999999 read", IndexError, 7},
+		{"\"a\" 99999999999999999999 read", IndexError, 7},
+		{"{ 1 } 2 99999999999999999999 replace", IndexError, 7},
+		{"{ 1 } 2 0 1 - replace", IndexError, 7},
+		{"{ 1 } 99999999999999999999 remove", IndexError, 7},
+		{"{ 1 } 0 1 - remove", IndexError, 7},
+		{"block do", SyntaxError, 2},
+		{"\"a\\qb\"", SyntaxError, 2},
+		{"'a\\qb'", SyntaxError, 2},
+		{"false assert \"no\"", AssertionError, 3},
+		{"1 0 /", ZeroDivisionError, 13},
+		{"3 exit", ExitSignal, 3},
+		{"exit", ExitSignal, 0},
+		{"300 exit", ValueError, 14},
+		{"\"x\" exit", TypeError, 6},
+		{"\"ab\" 9223372036854775807 repeat", ValueError, 14},
+		{"\"ab\" 99999999999999999999 repeat", ValueError, 14},
+		{"\"abc\" atof", ValueError, 14},
+		{"\"1e999\" atof", ValueError, 14},
+	}
+	for _, engine := range Engines {
+		for _, test := range tests {
+			_, err := NewTestInterpreter(engine.Engine).RunString(test.source, "test.tsp")
+			e, ok := err.(*Error)
+			if !ok {
+				t.Errorf("%s: %q: got %v, want a *Error", engine.Name, test.source, err)
+				continue
+			}
+			if e.Type != test.Type || e.ExitCode() != test.code {
+				t.Errorf("%s: %q: got %s (exit %d), want %s (exit %d)", engine.Name, test.source, e.Type, e.ExitCode(), test.Type, test.code)
+			}
+		}
+	}
+}
+
+func TestAtof(t *testing.T) {
+	for _, engine := range Engines {
+		stack, err := NewTestInterpreter(engine.Engine).RunString("\" 2.5 \" atof \"-1\" atof", "test.tsp")
+		if err != nil || FormatStack(stack) != "[2.5 -1.0]" {
+			t.Errorf("%s: got %s, %v, want [2.5 -1.0]", engine.Name, FormatStack(stack), err)
+		}
+		_, err = NewTestInterpreter(engine.Engine).RunString("\"abc\" atof", "test.tsp")
+		if e, ok := err.(*Error); !ok || e.Text() != "`atof` cannot convert \"abc\" to <float>." {
+			t.Errorf("%s: got %v, want a ValueError for \"abc\"", engine.Name, err)
+		}
+	}
+}
+
+// A bad escape is reported at the opening quote of the string.
+func TestInvalidString(t *testing.T) {
+	for _, source := range []string{"1\n  \"a\\qb\"", "1\n  'a\nb\\q'"} {
+		_, err := NewInterpreter().RunString(source, "test.tsp")
+		if e, ok := err.(*Error); !ok || e.Type != SyntaxError || e.Position.Line != 2 || e.Position.Column != 3 {
+			t.Errorf("%q: got %v, want a SyntaxError at 2:3", source, err)
+		}
+	}
+}
+
+// The stack and the variables are kept between runs.
+func TestRunStringKeepsState(t *testing.T) {
+	for _, engine := range Engines {
+		interpreter := NewTestInterpreter(engine.Engine)
+		runs := []string{"1 2", "+ -> x", "block double do 2 * end", "x double"}
+		for _, source := range runs {
+			if _, err := interpreter.RunString(source, "test.tsp"); err != nil {
+				t.Fatalf("%s: %q: unexpected error %v", engine.Name, source, err)
+			}
+		}
+		if got := FormatStack(interpreter.Stack()); got != "[6]" {
+			t.Errorf("%s: got stack %s, want [6]", engine.Name, got)
+		}
+		if x, ok := interpreter.Variables()["x"]; !ok || FormatValue(x, true) != "3" {
+			t.Errorf("%s: got x = %v, want 3", engine.Name, x)
+		}
+	}
+}
+
+// An error leaves the stack as it was when the error happened.
+func TestRunStringErrorStack(t *testing.T) {
+	for _, engine := range Engines {
+		stack, err := NewTestInterpreter(engine.Engine).RunString("1 2 nothing", "test.tsp")
+		if err == nil {
+			t.Fatalf("%s: expected an error", engine.Name)
+		}
+		if got := FormatStack(stack); got != "[1 2]" {
+			t.Errorf("%s: got stack %s, want [1 2]", engine.Name, got)
+		}
+		if got := FormatStack(err.(*Error).Stack); got != "[1 2]" {
+			t.Errorf("%s: got error stack %s, want [1 2]", engine.Name, got)
+		}
+	}
+}
+
+func TestInterpretersAreIsolated(t *testing.T) {
+	for _, engine := range Engines {
+		a, b := NewTestInterpreter(engine.Engine), NewTestInterpreter(engine.Engine)
+		if _, err := a.RunString("1 -> x block f do 2 end 3", "a.tsp"); err != nil {
+			t.Fatalf("%s: unexpected error %v", engine.Name, err)
+		}
+		if len(b.Stack()) != 0 {
+			t.Errorf("%s: the stack of another interpreter changed: %s", engine.Name, FormatStack(b.Stack()))
+		}
+		for _, source := range []string{"x", "f"} {
+			_, err := b.RunString(source, "b.tsp")
+			if e, ok := err.(*Error); !ok || e.Type != NameError {
+				t.Errorf("%s: %q: got %v, want a NameError", engine.Name, source, err)
+			}
+		}
+	}
+}
+
+// An exception belongs to the interpreter, and to the module, that
+// declares it.
+func TestExceptions(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a", "b"} {
+		os.WriteFile(filepath.Join(dir, name+".tsp"), []byte("exception ParseError\nblock fail do \"bad\" ParseError raise end\n"), 0644)
+	}
+	main := filepath.Join(dir, "main.tsp")
+	os.WriteFile(main, []byte("import \"a\"\nimport \"b\"\ntry a.fail except b.ParseError do 1 except a.ParseError -> e do e end\n"), 0644)
+	for _, engine := range Engines {
+		stack, err := NewTestInterpreter(engine.Engine).RunFile(main)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", engine.Name, err)
+		}
+		if got := FormatStack(stack); !strings.HasPrefix(got, "[<error 'a.ParseError': ") {
+			t.Errorf("%s: got %s, want an a.ParseError", engine.Name, got)
+		}
+		a, b := NewTestInterpreter(engine.Engine), NewTestInterpreter(engine.Engine)
+		if _, err := a.RunString("exception E", "a.tsp"); err != nil {
+			t.Fatalf("%s: unexpected error %v", engine.Name, err)
+		}
+		_, err = b.RunString("E", "b.tsp")
+		if e, ok := err.(*Error); !ok || e.Type != NameError {
+			t.Errorf("%s: got %v, want a NameError", engine.Name, err)
+		}
+		_, err = a.RunString("exception E \"x\" E raise", "a.tsp")
+		if e, ok := err.(*Error); !ok || e.TypeName() != "E" || e.Type < ErrorType(BuiltinErrorCount) {
+			t.Errorf("%s: got %v, want an E", engine.Name, err)
+		}
+	}
+}
+
+// A `break` in a try statement leaves the loop around it after the
+// finally body, so these loops end.
+func TestBreakInTry(t *testing.T) {
+	tests := []struct {
+		source string
+		stack string
+	}{
+		{"for true do try 1 break finally 2 end end", "[1 2]"},
+		{"for true do try drop except error do drop 1 break end end", "[1]"},
+		{"for true do try 1 finally break end end", "[1]"},
+		{"for true do try try break finally 1 end finally 2 end end 3", "[1 2 3]"},
+		{"block f do try break finally 1 end 2 end f", "[1]"},
+	}
+	for _, engine := range Engines {
+		for _, test := range tests {
+			done := make(chan string, 1)
+			go func() {
+				stack, err := NewTestInterpreter(engine.Engine).RunString(test.source, "test.tsp")
+				if err != nil {
+					done <- err.Error()
+					return
+				}
+				done <- FormatStack(stack)
+			}()
+			select {
+				case got := <-done:
+					if got != test.stack {
+						t.Errorf("%s: %q: got %s, want %s", engine.Name, test.source, got, test.stack)
+					}
+				case <-time.After(5 * time.Second):
+					t.Fatalf("%s: %q: the loop does not end", engine.Name, test.source)
+			}
+		}
+	}
+}
+
+func TestSetArgs(t *testing.T) {
+	for _, engine := range Engines {
+		interpreter := NewTestInterpreter(engine.Engine)
+		if got := FormatStack([]AST{interpreter.Variables()["argv"]}); got != "[{}]" {
+			t.Errorf("%s: got argv %s, want {}", engine.Name, got)
+		}
+		interpreter.SetArgs([]string{"a", "b"})
+		stack, err := interpreter.RunString("argv len argv 1 read", "test.tsp")
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", engine.Name, err)
+		}
+		if got := FormatStack(stack); got != "[2 \"b\"]" {
+			t.Errorf("%s: got %s, want [2 \"b\"]", engine.Name, got)
+		}
+	}
+}
+
+func TestSetOutput(t *testing.T) {
+	for _, engine := range Engines {
+		interpreter := NewTestInterpreter(engine.Engine)
+		var out strings.Builder
+		interpreter.SetOutput(&out)
+		if _, err := interpreter.RunString("1 print \" a\" println { 2 } println \"echo b\" system", "test.tsp"); err != nil {
+			t.Fatalf("%s: unexpected error %v", engine.Name, err)
+		}
+		if got := out.String(); got != "1 a\n{2}\nb\n" {
+			t.Errorf("%s: got output %q", engine.Name, got)
+		}
+	}
+}
+
+// The stack that is returned is a copy, so changing it does not change
+// the stack of the interpreter.
+func TestStackIsACopy(t *testing.T) {
+	for _, engine := range Engines {
+		interpreter := NewTestInterpreter(engine.Engine)
+		stack, err := interpreter.RunString("1 2", "test.tsp")
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", engine.Name, err)
+		}
+		stack[0] = AsInt{5}
+		interpreter.Stack()[1] = AsInt{6}
+		if got, _ := interpreter.RunString("+", "test.tsp"); FormatStack(got) != "[3]" {
+			t.Errorf("%s: got %s, want [3]", engine.Name, FormatStack(got))
+		}
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.tsp")
+	main := filepath.Join(dir, "main.tsp")
+	os.WriteFile(lib, []byte("block twice do 2 * end\n"), 0644)
+	os.WriteFile(main, []byte("import \"lib\"\n21 lib.twice\n"), 0644)
+	for _, engine := range Engines {
+		stack, err := NewTestInterpreter(engine.Engine).RunFile(main)
+		if err != nil {
+			t.Fatalf("%s: unexpected error %v", engine.Name, err)
+		}
+		if got := FormatStack(stack); got != "[42]" {
+			t.Errorf("%s: got %s, want [42]", engine.Name, got)
+		}
+		if _, err := NewTestInterpreter(engine.Engine).RunFile(filepath.Join(dir, "missing.tsp")); err == nil {
+			t.Errorf("%s: expected an error for a missing file", engine.Name)
+		}
+	}
+}
+
+// A file that includes itself stops at the call depth limit.
+func TestRecursiveInclude(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "self.tsp")
+	os.WriteFile(file, []byte("include \""+filepath.ToSlash(file)+"\"\n"), 0644)
+	for _, engine := range Engines {
+		interpreter := NewTestInterpreter(engine.Engine)
+		interpreter.Scope.MaxDepth = 50
+		_, err := interpreter.RunFile(file)
+		if e, ok := err.(*Error); !ok || e.Type != RecursionError {
+			t.Errorf("%s: got %v, want a RecursionError", engine.Name, err)
+		}
+	}
+}
@@ -0,0 +1,496 @@
+package tsharp
+
+import (
+	"fmt"
+	"bufio"
+	"io"
+	"unicode"
+	"strconv"
+)
+
+
+// -----------------------------
+// ----------- Lexer -----------
+// -----------------------------
+
+type Token int
+const (
+	TOKEN_EOF = iota
+	TOKEN_ILLEGAL
+	TOKEN_ID
+	TOKEN_STRING
+	TOKEN_INT
+	TOKEN_TYPE
+	TOKEN_PLUS
+	TOKEN_MINUS
+	TOKEN_END
+	TOKEN_DO
+	TOKEN_BOOL
+	TOKEN_ELIF
+	TOKEN_ELSE
+	TOKEN_DIV
+	TOKEN_MUL
+	TOKEN_EQUALS
+	TOKEN_IS_EQUALS
+	TOKEN_NOT_EQUALS
+	TOKEN_LESS_THAN
+	TOKEN_GREATER_THAN
+	TOKEN_LESS_EQUALS
+	TOKEN_GREATER_EQUALS
+	TOKEN_REM
+	TOKEN_L_BRACKET
+	TOKEN_R_BRACKET
+	TOKEN_DOT
+	TOKEN_COMMA
+	TOKEN_ERROR
+	TOKEN_EXCEPT
+	TOKEN_OR
+	TOKEN_AND
+	TOKEN_FINALLY
+	TOKEN_L_PAREN
+	TOKEN_R_PAREN
+	TOKEN_DOUBLE_MINUS
+	TOKEN_FLOAT
+	TOKEN_L_SQUARE
+	TOKEN_R_SQUARE
+	TOKEN_FAT_ARROW
+	TOKEN_COMMENT
+)
+
+var tokens = []string{
+	TOKEN_PLUS:           "+",
+	TOKEN_MINUS:          "-",
+	TOKEN_DIV:            "/",
+	TOKEN_MUL:            "*",
+	TOKEN_IS_EQUALS:      "==",
+	TOKEN_NOT_EQUALS:     "!=",
+	TOKEN_LESS_THAN:      "<",
+	TOKEN_GREATER_THAN:   ">",
+	TOKEN_LESS_EQUALS:    "<=",
+	TOKEN_GREATER_EQUALS: ">=",
+	TOKEN_REM:            "%",
+	TOKEN_OR:             "||",
+	TOKEN_AND:            "&&",
+}
+
+type Position struct {
+	line int
+	column int
+}
+
+// Lexer reads the tokens of a file. Comments are skipped, unless
+// KeepComments is set, for `tsh fmt`: then each is a TOKEN_COMMENT.
+type Lexer struct {
+	pos Position
+	reader *bufio.Reader
+	FileName string
+	KeepComments bool
+}
+
+func LexerInit(reader io.Reader, FileName string) *Lexer {
+	return &Lexer{
+		pos:    Position {line: 1, column: 0},
+		reader: bufio.NewReader(reader),
+		FileName: FileName,
+	}
+}
+
+func (lexer *Lexer) Lex() (Position, Token, string, string, *Error) {
+	for {
+		r, _, err := lexer.reader.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				return lexer.pos, TOKEN_EOF, "EOF", lexer.FileName, nil
+			}
+			return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+		}
+		lexer.pos.column++
+		switch r {
+			case '\n': lexer.resetPosition()
+			case '+': return lexer.pos, TOKEN_PLUS, "+", lexer.FileName, nil
+			case '/': return lexer.pos, TOKEN_DIV, "/", lexer.FileName, nil
+			case '*': return lexer.pos, TOKEN_MUL, "*", lexer.FileName, nil
+			case '%': return lexer.pos, TOKEN_REM, "%", lexer.FileName, nil
+			case '{': return lexer.pos, TOKEN_L_BRACKET, "{", lexer.FileName, nil
+			case '}': return lexer.pos, TOKEN_R_BRACKET, "}", lexer.FileName, nil
+			case '[': return lexer.pos, TOKEN_L_SQUARE, "[", lexer.FileName, nil
+			case ']': return lexer.pos, TOKEN_R_SQUARE, "]", lexer.FileName, nil
+			case '(': return lexer.pos, TOKEN_L_PAREN, "(", lexer.FileName, nil
+			case ')': return lexer.pos, TOKEN_R_PAREN, ")", lexer.FileName, nil
+			case ',': return lexer.pos, TOKEN_COMMA, ",", lexer.FileName, nil
+			case '.': return lexer.pos, TOKEN_DOT, ".", lexer.FileName, nil
+			default:
+				if unicode.IsSpace(r) {
+					continue
+				} else if r == '=' {
+					r, _, err := lexer.reader.ReadRune()
+					if err != nil {
+						if err == io.EOF {
+							return lexer.pos, TOKEN_ILLEGAL, "=", lexer.FileName, lexer.unexpected("=")
+						}
+						return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+					}
+					lexer.pos.column++
+					if r == '=' {
+						return lexer.pos, TOKEN_IS_EQUALS, "==", lexer.FileName, nil
+					} else if r == '>' {
+						return lexer.pos, TOKEN_FAT_ARROW, "=>", lexer.FileName, nil
+					}
+					return lexer.pos, TOKEN_ILLEGAL, string(r), lexer.FileName, lexer.unexpected(string(r))
+				} else if r == '-' {
+					r, _, err := lexer.reader.ReadRune()
+					if err != nil {
+						if err == io.EOF {
+							return lexer.pos, TOKEN_MINUS, "-", lexer.FileName, nil
+						}
+						return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+					}
+					lexer.pos.column++
+					if r == '>' {
+						return lexer.pos, TOKEN_EQUALS, "->", lexer.FileName, nil
+					} else if r == '-' {
+						return lexer.pos, TOKEN_DOUBLE_MINUS, "--", lexer.FileName, nil
+					} else {
+						lexer.backup()
+						return lexer.pos, TOKEN_MINUS, "-", lexer.FileName, nil
+					}
+				} else if r == '<' {
+					r, _, err := lexer.reader.ReadRune()
+					if err != nil {
+						if err == io.EOF {
+							return lexer.pos, TOKEN_LESS_THAN, "<", lexer.FileName, nil
+						}
+						return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+					}
+					lexer.pos.column++
+					if r == '=' {
+						return lexer.pos, TOKEN_LESS_EQUALS, "<=", lexer.FileName, nil
+					} else {
+						lexer.backup()
+						return lexer.pos, TOKEN_LESS_THAN, "<", lexer.FileName, nil
+					}
+				} else if r == '|' || r == '&' {
+					first := r
+					r, _, err := lexer.reader.ReadRune()
+					if err != nil {
+						if err == io.EOF {
+							return lexer.pos, TOKEN_ILLEGAL, string(first), lexer.FileName, lexer.unexpected(string(first))
+						}
+						return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+					}
+					lexer.pos.column++
+					if r == '|' && first == '|' {
+						return lexer.pos, TOKEN_OR, "||", lexer.FileName, nil
+					} else if r == '&' && first == '&' {
+						return lexer.pos, TOKEN_AND, "&&", lexer.FileName, nil
+					}
+					return lexer.pos, TOKEN_ILLEGAL, string(r), lexer.FileName, lexer.unexpected(string(r))
+				} else if r == '>' {
+					r, _, err := lexer.reader.ReadRune()
+					if err != nil {
+						if err == io.EOF {
+							return lexer.pos, TOKEN_GREATER_THAN, ">", lexer.FileName, nil
+						}
+						return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+					}
+					lexer.pos.column++
+					if r == '=' {
+						return lexer.pos, TOKEN_GREATER_EQUALS, ">=", lexer.FileName, nil
+					} else {
+						lexer.backup()
+						return lexer.pos, TOKEN_GREATER_THAN, ">", lexer.FileName, nil
+					}
+				} else if r == '!' {
+					r, _, err := lexer.reader.ReadRune()
+					if err != nil {
+						if err == io.EOF {
+							return lexer.pos, TOKEN_ILLEGAL, "!", lexer.FileName, lexer.unexpected("!")
+						}
+						return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+					}
+					lexer.pos.column++
+					if r == '=' {
+						return lexer.pos, TOKEN_NOT_EQUALS, "!=", lexer.FileName, nil
+					}
+					return lexer.pos, TOKEN_ILLEGAL, string(r), lexer.FileName, lexer.unexpected(string(r))
+				} else if r == '#' {
+					startPos := lexer.pos
+					val := "#"
+					for {
+						r, _, err := lexer.reader.ReadRune()
+						if err != nil {
+							if err == io.EOF {
+								if lexer.KeepComments {
+									return startPos, TOKEN_COMMENT, val, lexer.FileName, nil
+								}
+								return lexer.pos, TOKEN_EOF, "EOF", lexer.FileName, nil
+							}
+							return lexer.pos, TOKEN_ILLEGAL, "", lexer.FileName, lexer.readError(err)
+						}
+						if r == '\n' {
+							lexer.resetPosition()
+							break
+						}
+						lexer.pos.column++
+						if lexer.KeepComments {
+							val = val + string(r)
+						}
+					}
+					if lexer.KeepComments {
+						return startPos, TOKEN_COMMENT, val, lexer.FileName, nil
+					}
+					continue
+				} else if unicode.IsDigit(r) {
+					startPos := lexer.pos
+					lexer.backup()
+					val, tok, err := lexer.lexNumber()
+					if err != nil {
+						return startPos, TOKEN_ILLEGAL, val, lexer.FileName, err
+					}
+					return startPos, tok, val, lexer.FileName, nil
+				} else if unicode.IsLetter(r) {
+					startPos := lexer.pos
+					lexer.backup()
+					val, err := lexer.lexId()
+					if err != nil {
+						return startPos, TOKEN_ILLEGAL, val, lexer.FileName, err
+					}
+					if val == "end" {
+						return startPos, TOKEN_END, val, lexer.FileName, nil
+					} else if val == "do" {
+						return startPos, TOKEN_DO, val, lexer.FileName, nil
+					} else if val == "true" || val == "false" {
+						return startPos, TOKEN_BOOL, val, lexer.FileName, nil
+					} else if val == "string" || val == "int" || val == "bool" || val == "type" || val == "list" || val == "error" || val == "float" || val == "map" || val == "quote" || val == "module" {
+						return startPos, TOKEN_TYPE, val, lexer.FileName, nil
+					} else if val == "else" {
+						return startPos, TOKEN_ELSE, val, lexer.FileName, nil
+					} else if val == "elif" {
+						return startPos, TOKEN_ELIF, val, lexer.FileName, nil
+					} else if IsBuiltinError(val) {
+						return startPos, TOKEN_ERROR, val, lexer.FileName, nil
+					} else if val == "except" {
+						return startPos, TOKEN_EXCEPT, val, lexer.FileName, nil
+					} else if val == "finally" {
+						return startPos, TOKEN_FINALLY, val, lexer.FileName, nil
+					}
+					return startPos, TOKEN_ID, val, lexer.FileName, nil
+				} else if r == '"' || r == '\'' {
+					startPos := lexer.pos
+					lexer.backup()
+					var val string
+					var LexErr *Error
+					if r == '"' {
+						val, LexErr = lexer.lexString()
+					} else {
+						val, LexErr = lexer.lexStringSingle()
+					}
+					if LexErr != nil {
+						return startPos, TOKEN_ILLEGAL, val, lexer.FileName, LexErr
+					}
+					val, UnquoteErr := strconv.Unquote(val)
+					if UnquoteErr != nil {
+						return startPos, TOKEN_ILLEGAL, val, lexer.FileName, lexer.invalidString(startPos)
+					}
+					return startPos, TOKEN_STRING, val, lexer.FileName, nil
+				} else {
+					return lexer.pos, TOKEN_ILLEGAL, string(r), lexer.FileName, lexer.unexpected(string(r))
+				}
+        }
+	}
+}
+
+func (lexer *Lexer) unexpected(value string) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: unexpected token value `%s`.", lexer.FileName, lexer.pos.line, lexer.pos.column, value)
+	err.Type = SyntaxError
+	err.Position = NodePosition{lexer.FileName, lexer.pos.line, lexer.pos.column}
+	return &err
+}
+
+func (lexer *Lexer) readError(ReadErr error) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: %s.", lexer.FileName, lexer.pos.line, lexer.pos.column, ReadErr.Error())
+	err.Type = SyntaxError
+	err.Position = NodePosition{lexer.FileName, lexer.pos.line, lexer.pos.column}
+	return &err
+}
+
+func (lexer *Lexer) backup() {
+	lexer.reader.UnreadRune()
+	lexer.pos.column--
+}
+
+func (lexer *Lexer) lexId() (string, *Error) {
+	var val string
+	for {
+		r, _, err := lexer.reader.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				return val, nil
+			}
+			return val, lexer.readError(err)
+		}
+		lexer.pos.column++
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '_' || r == '-' || r == '.' {
+			val = val + string(r)
+		} else {
+			lexer.backup()
+			return val, nil
+		}
+	}
+}
+
+// lexNumber reads an int, or a float such as `3.14` or `1e-9`.
+func (lexer *Lexer) lexNumber() (string, Token, *Error) {
+	var val string
+	tok := Token(TOKEN_INT)
+	// state: 0 integer part, 1 after `.`, 2 fraction, 3 after `e`, 4 after the exponent sign, 5 exponent
+	state := 0
+	for {
+		r, _, err := lexer.reader.ReadRune()
+		if err != nil && err != io.EOF {
+			return val, tok, lexer.readError(err)
+		}
+		if err == nil {
+			lexer.pos.column++
+		}
+		switch {
+			case err == nil && unicode.IsDigit(r):
+				val = val + string(r)
+				switch state {
+					case 1: state = 2
+					case 3, 4: state = 5
+				}
+				continue
+			case err == nil && r == '.' && state == 0:
+				val = val + string(r)
+				tok = TOKEN_FLOAT
+				state = 1
+				continue
+			case err == nil && (r == 'e' || r == 'E') && (state == 0 || state == 2):
+				val = val + string(r)
+				tok = TOKEN_FLOAT
+				state = 3
+				continue
+			case err == nil && (r == '-' || r == '+') && state == 3:
+				val = val + string(r)
+				state = 4
+				continue
+		}
+		if state == 1 || state == 3 || state == 4 {
+			if err == nil {
+				return val, tok, lexer.unexpected(val + string(r))
+			}
+			return val, tok, lexer.unexpected(val)
+		}
+		if err == nil {
+			lexer.backup()
+		}
+		return val, tok, nil
+	}
+}
+
+func (lexer *Lexer) lexString() (string, *Error) {
+	var val string
+	r, _, _ := lexer.reader.ReadRune()
+	lexer.pos.column++
+	val = val + string(r)
+	for {
+		r, _, err := lexer.reader.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				return val, lexer.unterminated()
+			}
+			return val, lexer.readError(err)
+		}
+		lexer.pos.column++
+		if r == '\n' {
+			lexer.resetPosition()
+			val = val + "\\n"
+			continue
+		}
+		val = val + string(r)
+		if r == '\\' {
+			r, _, err = lexer.reader.ReadRune()
+			if err != nil {
+				if err == io.EOF {
+					return val, lexer.unterminated()
+				}
+				return val, lexer.readError(err)
+			}
+			lexer.pos.column++
+			val = val + string(r)
+		} else if r == '"' {
+			return val, nil
+		}
+	}
+}
+
+func (lexer *Lexer) lexStringSingle() (string, *Error) {
+	var val string
+	lexer.reader.ReadRune()
+	lexer.pos.column++
+	val = val + string("\"")
+	for {
+		r, _, err := lexer.reader.ReadRune()
+		if err != nil {
+			if err == io.EOF {
+				return val, lexer.unterminated()
+			}
+			return val, lexer.readError(err)
+		}
+		lexer.pos.column++
+		if r == '\n' {
+			lexer.resetPosition()
+			val = val + "\\n"
+			continue
+		}
+		if r == '\'' {
+			val = val + "\""
+			return val, nil
+		}
+		if r == '"' {
+			val = val + "\\\""
+			continue
+		}
+		val = val + string(r)
+		if r == '\\' {
+			r, _, err = lexer.reader.ReadRune()
+			if err != nil {
+				if err == io.EOF {
+					return val, lexer.unterminated()
+				}
+				return val, lexer.readError(err)
+			}
+			lexer.pos.column++
+			if r == '\'' {
+				val = val[:len(val)-1]
+			}
+			val = val + string(r)
+		}
+	}
+}
+
+func (lexer *Lexer) unterminated() (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: unterminated string literal.", lexer.FileName, lexer.pos.line, lexer.pos.column)
+	err.Type = SyntaxError
+	err.Position = NodePosition{lexer.FileName, lexer.pos.line, lexer.pos.column}
+	return &err
+}
+
+// invalidString is the error for a string literal with a bad escape, at
+// the opening quote.
+func (lexer *Lexer) invalidString(pos Position) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: invalid string literal.", lexer.FileName, pos.line, pos.column)
+	err.Type = SyntaxError
+	err.Position = NodePosition{lexer.FileName, pos.line, pos.column}
+	return &err
+}
+
+func (lexer *Lexer) resetPosition() {
+	lexer.pos.line++
+	lexer.pos.column = 0
+}
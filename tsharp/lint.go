@@ -0,0 +1,324 @@
+package tsharp
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+
+// -----------------------------
+// ---------- Linter -----------
+// -----------------------------
+
+// LintIssue is a mistake `tsh lint` found. Rule names the kind of
+// mistake: unused-variable, unused-block, break-outside-for,
+// unreachable-code or unbalanced-if.
+type LintIssue struct {
+	Position NodePosition
+	Rule string
+	Message string
+}
+
+func (issue LintIssue) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", issue.Position.FileName, issue.Position.Line, issue.Position.Column, issue.Rule, issue.Message)
+}
+
+// Linter walks the AST of a file. Used holds the names the other files
+// linted with it read, so a block or a global a module defines for
+// them is not unused, and Module is set when one of them imports the
+// file, or it is a module of the standard library: its top-level names
+// are then all for the files that import it. Checker works out the stack depths of `if` branches.
+type Linter struct {
+	Issues []LintIssue
+	Used map[string]bool
+	Module bool
+	Checker *Checker
+}
+
+// LintFiles lints the files together, and returns what it found in
+// each, sorted by position, and the files that did not parse.
+func LintFiles(files []string) ([]LintIssue, []*Error) {
+	asts := map[string]AST{}
+	errors := []*Error{}
+	for i := 0; i < len(files); i++ {
+		ast, err := ParseInclude(files[i], NodePosition{files[i], 1, 1})
+		if err != nil {
+			errors = append(errors, err)
+			continue
+		}
+		asts[files[i]] = ast
+	}
+	imported := Imported(asts)
+	issues := []LintIssue{}
+	for i := 0; i < len(files); i++ {
+		ast, ok := asts[files[i]]
+		if !ok {
+			continue
+		}
+		used := map[string]bool{}
+		for j := 0; j < len(files); j++ {
+			if other, ok := asts[files[j]]; ok && j != i {
+				for name := range Reads(other) {
+					used[name] = true
+				}
+			}
+		}
+		key := ModuleKey(files[i])
+		issues = append(issues, Lint(ast, used, imported[key] || IsStdlibFile(key))...)
+	}
+	return issues, errors
+}
+
+// Imported returns the absolute paths of the files the ASTs import, by
+// the path of the file of each AST.
+func Imported(asts map[string]AST) map[string]bool {
+	imported := map[string]bool{}
+	for file, ast := range asts {
+		scope := InitScope()
+		scope.Modules.Root = filepath.Dir(file)
+		Walk(ast, func(node AST) bool {
+			if node, ok := node.(Import); ok {
+				if _, key, err := scope.ResolveImport(node.Path, node.Position); err == nil {
+					imported[key] = true
+				}
+			}
+			return true
+		})
+	}
+	return imported
+}
+
+// ModuleKey is the path an import of the file resolves to: its absolute
+// path, or the path in the standard library when it is the source of a
+// module of it.
+func ModuleKey(FileName string) string {
+	if path, ok := StdlibFile(filepath.Base(FileName)); ok {
+		source, _ := StdlibSource(path)
+		if content, err := os.ReadFile(FileName); err == nil && string(content) == source {
+			return path
+		}
+	}
+	key, err := filepath.Abs(FileName)
+	if err != nil {
+		return filepath.Clean(FileName)
+	}
+	return key
+}
+
+// Lint returns the mistakes in the AST of a file. A top-level name in
+// used counts as read, and every top-level name of a module, a file that
+// another one imports.
+func Lint(ast AST, used map[string]bool, module bool) []LintIssue {
+	linter := &Linter{Used: used, Module: module, Checker: CheckerInit()}
+	linter.Checker.Collect(ast)
+	linter.LintScope(ast, true)
+	sort.SliceStable(linter.Issues, func(i, j int) bool {
+		a, b := linter.Issues[i].Position, linter.Issues[j].Position
+		return a.Line < b.Line || a.Line == b.Line && a.Column < b.Column
+	})
+	return linter.Issues
+}
+
+func (linter *Linter) Report(position NodePosition, rule string, format string, args ...interface{}) {
+	linter.Issues = append(linter.Issues, LintIssue{position, rule, fmt.Sprintf(format, args...)})
+}
+
+// Reads counts the names read in a body, in the blocks and quotations
+// in it too. `math.sqrt` reads `math`, and `sqrt` of the module.
+func Reads(node AST) map[string]int {
+	names := map[string]int{}
+	Walk(node, func(node AST) bool {
+		if push, ok := node.(AsPush); ok {
+			if value, ok := push.value.(Var); ok {
+				names[value.Name]++
+				if value.Member != "" {
+					names[value.Member]++
+				}
+			}
+		}
+		return true
+	})
+	return names
+}
+
+// Walk calls visit on the node and the nodes in it, depth first. The
+// nodes in a node are skipped when visit returns false.
+func Walk(node AST, visit func(AST) bool) {
+	if node == nil || !visit(node) {
+		return
+	}
+	switch node.(type) {
+		case AsStatements:
+			for i := 0; i < len(node.(AsStatements)); i++ {
+				Walk(node.(AsStatements)[i], visit)
+			}
+		case AsPush:
+			switch node.(AsPush).value.(type) {
+				case NewList: Walk(node.(AsPush).value.(NewList).ListBody, visit)
+				case NewMap: Walk(node.(AsPush).value.(NewMap).MapBody, visit)
+				case AsQuote: Walk(node.(AsPush).value.(AsQuote).Block.BlockBody, visit)
+			}
+		case Blockdef:
+			Walk(node.(Blockdef).BlockBody, visit)
+		case If:
+			Walk(node.(If).IfOp, visit)
+			Walk(node.(If).IfBody, visit)
+			for i := 0; i < len(node.(If).ElifOps); i++ {
+				Walk(node.(If).ElifOps[i], visit)
+				Walk(node.(If).ElifBodys[i], visit)
+			}
+			Walk(node.(If).ElseBody, visit)
+		case For:
+			Walk(node.(For).ForOp, visit)
+			Walk(node.(For).ForBody, visit)
+		case Try:
+			Walk(node.(Try).TryBody, visit)
+			for i := 0; i < len(node.(Try).ExceptBodys); i++ {
+				Walk(node.(Try).ExceptBodys[i], visit)
+			}
+			Walk(node.(Try).FinallyBody, visit)
+	}
+}
+
+// LintScope lints the body of a file, a block or a quotation: the
+// variables and blocks it defines, which its own code, or the blocks
+// and quotations in it, must use.
+func (linter *Linter) LintScope(body AST, top bool) {
+	reads := Reads(body)
+	vars := []Vardef{}
+	blocks := []Blockdef{}
+	Walk(body, func(node AST) bool {
+		switch node.(type) {
+			case Vardef:
+				if !node.(Vardef).Outer {
+					vars = append(vars, node.(Vardef))
+				}
+			case Try:
+				for i := 0; i < len(node.(Try).ExceptNames); i++ {
+					if node.(Try).ExceptNames[i] != "" {
+						vars = append(vars, Vardef{Name: node.(Try).ExceptNames[i], Position: node.(Try).ExceptPositions[i]})
+					}
+				}
+			case Blockdef:
+				blocks = append(blocks, node.(Blockdef))
+				return false
+			case AsPush:
+				if _, ok := node.(AsPush).value.(AsQuote); ok {
+					return false
+				}
+		}
+		return true
+	})
+	reported := map[string]bool{}
+	for i := 0; i < len(vars); i++ {
+		name := vars[i].Name
+		if reads[name] == 0 && !(top && (linter.Module || linter.Used[name])) && !reported[name] {
+			linter.Report(vars[i].Position, "unused-variable", "`%s` is defined with `->` but never read.", name)
+			reported[name] = true
+		}
+	}
+	for i := 0; i < len(blocks); i++ {
+		name := blocks[i].Name
+		if strings.HasPrefix(name, "test_") || top && (linter.Module || linter.Used[name]) {
+			continue
+		}
+		if reads[name]-Reads(blocks[i].BlockBody)[name] == 0 {
+			linter.Report(blocks[i].Position, "unused-block", "block `%s` is never called.", name)
+		}
+	}
+	linter.LintBody(body, false)
+}
+
+// LintBody looks for the mistakes in the statements of a body. InLoop
+// is set in the body of a `for`.
+func (linter *Linter) LintBody(node AST, InLoop bool) {
+	if node == nil {
+		return
+	}
+	switch node.(type) {
+		case AsStatements:
+			statements := node.(AsStatements)
+			for i := 0; i < len(statements); i++ {
+				linter.LintBody(statements[i], InLoop)
+				if word, ok := statements[i].(AsId); ok && i < len(statements)-1 {
+					switch word.name {
+						case "exit", "break", "raise", "rethrow":
+							linter.Report(word.Position, "unreachable-code", "the code after `%s` is never run.", word.name)
+					}
+				}
+			}
+		case AsId:
+			if node.(AsId).name == "break" && !InLoop {
+				linter.Report(node.(AsId).Position, "break-outside-for", "`break` is not inside a `for` loop.")
+			}
+		case AsPush:
+			switch node.(AsPush).value.(type) {
+				case NewList: linter.LintBody(node.(AsPush).value.(NewList).ListBody, InLoop)
+				case NewMap: linter.LintBody(node.(AsPush).value.(NewMap).MapBody, InLoop)
+				case AsQuote: linter.LintScope(node.(AsPush).value.(AsQuote).Block.BlockBody, false)
+			}
+		case Blockdef:
+			linter.LintScope(node.(Blockdef).BlockBody, false)
+		case If:
+			linter.LintIf(node.(If))
+			linter.LintBody(node.(If).IfOp, InLoop)
+			linter.LintBody(node.(If).IfBody, InLoop)
+			for i := 0; i < len(node.(If).ElifOps); i++ {
+				linter.LintBody(node.(If).ElifOps[i], InLoop)
+				linter.LintBody(node.(If).ElifBodys[i], InLoop)
+			}
+			linter.LintBody(node.(If).ElseBody, InLoop)
+		case For:
+			linter.LintBody(node.(For).ForOp, true)
+			linter.LintBody(node.(For).ForBody, true)
+		case Try:
+			linter.LintBody(node.(Try).TryBody, InLoop)
+			for i := 0; i < len(node.(Try).ExceptBodys); i++ {
+				linter.LintBody(node.(Try).ExceptBodys[i], InLoop)
+			}
+			linter.LintBody(node.(Try).FinallyBody, InLoop)
+	}
+}
+
+// LintIf reports an `if` whose branches leave different stack depths.
+// The branches are checked from an open stack, like a block without a
+// stack effect, so the `if` is checked wherever it is; a branch that
+// does not return, or whose effect is not known, is left out.
+func (linter *Linter) LintIf(node If) {
+	checker := linter.Checker
+	saved := checker.Errors
+	defer func() { checker.Errors = saved }()
+	locals := map[string]string{}
+	state := &TypeStack{Types: []string{}, Open: true}
+	checker.CheckCondition(node.IfOp, node.Position, "if", state, locals)
+	branches := []*TypeStack{state.Copy()}
+	checker.CheckBody(node.IfBody, branches[0], locals)
+	for i := 0; i < len(node.ElifOps); i++ {
+		checker.CheckCondition(node.ElifOps[i], node.ElifPositions[i], "elif", state, locals)
+		branch := state.Copy()
+		checker.CheckBody(node.ElifBodys[i], branch, locals)
+		branches = append(branches, branch)
+	}
+	if node.ElseBody != nil {
+		checker.CheckBody(node.ElseBody, state, locals)
+	}
+	branches = append(branches, state)
+	depths := []int{}
+	for i := 0; i < len(branches); i++ {
+		if branches[i].Unknown {
+			return
+		}
+		if !branches[i].Dead {
+			depths = append(depths, branches[i].Depth())
+		}
+	}
+	for i := 1; i < len(depths); i++ {
+		if depths[i] != depths[0] {
+			linter.Report(node.Position, "unbalanced-if", "if branches leave different stack depths (%d and %d).", depths[0], depths[i])
+			return
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package tsharp
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func ParseTestSource(t *testing.T, source string) AST {
+	t.Helper()
+	parser, err := ParserInit(LexerInit(strings.NewReader(source), "test.tsp"))
+	if err != nil {
+		t.Fatalf("%q: unexpected error %v", source, err)
+	}
+	ast, err := ParserParse(parser)
+	if err != nil {
+		t.Fatalf("%q: unexpected error %v", source, err)
+	}
+	return ast
+}
+
+func LintSource(t *testing.T, source string) []string {
+	t.Helper()
+	rules := []string{}
+	for _, issue := range Lint(ParseTestSource(t, source), map[string]bool{}, false) {
+		rules = append(rules, issue.Rule)
+	}
+	return rules
+}
+
+func TestLint(t *testing.T) {
+	tests := []struct {
+		source string
+		rules string
+	}{
+		{"block f do 1 end block g do 2 end f", "unused-block"},
+		{"1 -> x 2 -> y x", "unused-variable"},
+		{"block f do 1 -> x end f", "unused-variable"},
+		{"break", "break-outside-for"},
+		{"block f do 1 exit 2 end f", "unreachable-code"},
+		{"if true do 1 end", "unbalanced-if"},
+		{"try drop except error -> e do 1 end", "unused-variable"},
+		{"block f do 1 end f -> x", "unused-variable"},
+	}
+	for _, test := range tests {
+		if got := strings.Join(LintSource(t, test.source), " "); got != test.rules {
+			t.Errorf("%q: got %q, want %q", test.source, got, test.rules)
+		}
+	}
+}
+
+// An unused `except ... -> e` is reported where `e` is.
+func TestLintExceptName(t *testing.T) {
+	issues := Lint(ParseTestSource(t, "try\n    drop\nexcept error -> e do\n    1\nend\n"), map[string]bool{}, false)
+	if len(issues) != 1 || issues[0].Position.Line != 3 || issues[0].Position.Column != 17 {
+		t.Errorf("got %v, want an unused-variable at 3:17", issues)
+	}
+}
+
+// The top-level names of a file another one imports are for it, and the
+// ones of a file nothing imports are not, even when it only defines
+// names.
+func TestLintFilesModule(t *testing.T) {
+	dir := t.TempDir()
+	lib := filepath.Join(dir, "lib.tsp")
+	alone := filepath.Join(dir, "alone.tsp")
+	main := filepath.Join(dir, "main.tsp")
+	WriteTestFile(t, lib, "import \"io\"\n3.14 -> pi\nexception Bad\nblock f do 1 end\nblock g do 1 -> x end\n")
+	WriteTestFile(t, alone, "block h do 1 end\n")
+	WriteTestFile(t, main, "import \"lib\"\nlib.f println\n")
+	issues, errors := LintFiles([]string{lib, alone, main})
+	if len(errors) != 0 {
+		t.Fatalf("unexpected errors %v", errors)
+	}
+	got := []string{}
+	for _, issue := range issues {
+		got = append(got, filepath.Base(issue.Position.FileName)+" "+issue.Rule)
+	}
+	if want := "lib.tsp unused-variable alone.tsp unused-block"; strings.Join(got, " ") != want {
+		t.Errorf("got %q, want %q", strings.Join(got, " "), want)
+	}
+	// a module of the standard library is imported by its name
+	if issues, errors := LintFiles([]string{"stdlib/math.tsp"}); len(issues) != 0 || len(errors) != 0 {
+		t.Errorf("stdlib/math.tsp: got %v, %v", issues, errors)
+	}
+}
@@ -0,0 +1,875 @@
+package tsharp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+
+// -----------------------------
+// ------ Language server ------
+// -----------------------------
+
+// DocToken is a token of a document. Line and Column are those of its
+// first character, and End the column after its last one, counted in
+// characters from 1.
+type DocToken struct {
+	Type Token
+	Value string
+	Line int
+	Column int
+	End int
+}
+
+// DocSymbol is a name a document defines: a block, a variable, an
+// exception or a module. It is defined in the scope Scope, and a block
+// opens the scope Body. Token is its name, and Start and Stop the first
+// and the last token of its definition.
+type DocSymbol struct {
+	Name string
+	Kind string
+	Detail string
+	Path string
+	Token DocToken
+	Start DocToken
+	Stop DocToken
+	Scope int
+	Body int
+}
+
+// DocScope is the body of the document, of a block or of a quotation,
+// from the `do` Start to the `end` Stop. Stop.Line is 0 until the body
+// is closed. Symbol is the block of the body, or -1.
+type DocScope struct {
+	Parent int
+	Start DocToken
+	Stop DocToken
+	Symbol int
+}
+
+// Document is a file open in the editor, with what the language server
+// knows of it. It is read from the tokens, so most of it still works
+// while the file does not parse.
+type Document struct {
+	URI string
+	Path string
+	Text string
+	Lines []string
+	Tokens []DocToken
+	Symbols []DocSymbol
+	Scopes []DocScope
+}
+
+var Keywords = []string{
+	"block", "do", "end", "if", "elif", "else", "for", "try", "except", "finally",
+	"import", "as", "include", "exception", "assert", "true", "false",
+	"string", "int", "float", "bool", "list", "map", "quote", "type", "error", "module", "any",
+}
+
+func NewDocument(uri string, text string) *Document {
+	doc := &Document{URI: uri, Path: URIToPath(uri), Text: text, Lines: strings.Split(text, "\n")}
+	doc.Tokenize()
+	doc.Analyze()
+	return doc
+}
+
+// Tokenize reads the tokens of the document, up to the first one the
+// lexer cannot read.
+func (doc *Document) Tokenize() {
+	lexer := LexerInit(strings.NewReader(doc.Text), doc.Path)
+	for {
+		pos, tok, val, _, err := lexer.Lex()
+		if err != nil || tok == TOKEN_EOF {
+			return
+		}
+		// the lexer is on the last character of the token
+		token := DocToken{Type: tok, Value: val, Line: pos.line, End: lexer.pos.column + 1}
+		if tok == TOKEN_STRING {
+			// the value of a string is unquoted, so it starts where the lexer says
+			token.Column = pos.column
+			if lexer.pos.line != pos.line {
+				token.End = utf8.RuneCountInString(doc.Line(pos.line)) + 1
+			}
+		} else {
+			token.Column = token.End - utf8.RuneCountInString(val)
+		}
+		doc.Tokens = append(doc.Tokens, token)
+	}
+}
+
+func (doc *Document) Line(line int) string {
+	if line < 1 || line > len(doc.Lines) {
+		return ""
+	}
+	return doc.Lines[line-1]
+}
+
+// TokenAfter returns the token after the ith one, if it has the type.
+func (doc *Document) TokenAfter(i int, tok Token) (DocToken, bool) {
+	if i+1 < len(doc.Tokens) && doc.Tokens[i+1].Type == tok {
+		return doc.Tokens[i+1], true
+	}
+	return DocToken{}, false
+}
+
+// Analyze finds the symbols and the scopes of the document. Each open
+// body is on a stack of frames, with the scope it opened or -1; the
+// `do` after a `block` opens a scope.
+func (doc *Document) Analyze() {
+	doc.Scopes = []DocScope{{Parent: -1, Symbol: -1}}
+	frames := []int{}
+	pending := -2
+	current := func() int {
+		for i := len(frames)-1; i >= 0; i-- {
+			if frames[i] >= 0 {
+				return frames[i]
+			}
+		}
+		return 0
+	}
+	pop := func(token DocToken) {
+		if len(frames) == 0 {
+			return
+		}
+		if scope := frames[len(frames)-1]; scope >= 0 {
+			doc.Scopes[scope].Stop = token
+			if doc.Scopes[scope].Symbol >= 0 {
+				doc.Symbols[doc.Scopes[scope].Symbol].Stop = token
+			}
+		}
+		frames = frames[:len(frames)-1]
+	}
+	for i := 0; i < len(doc.Tokens); i++ {
+		token := doc.Tokens[i]
+		switch token.Type {
+			case TOKEN_ID:
+				switch token.Value {
+					case "block":
+						pending = -1
+						if name, ok := doc.TokenAfter(i, TOKEN_ID); ok {
+							detail := "block " + name.Value
+							for j := i+2; j < len(doc.Tokens) && doc.Tokens[j].Type != TOKEN_DO; j++ {
+								detail += " " + doc.Tokens[j].Value
+							}
+							pending = doc.Define(DocSymbol{Name: name.Value, Kind: "block", Detail: detail, Token: name, Start: token, Stop: name, Scope: current(), Body: -1})
+						}
+					case "try": frames = append(frames, -1)
+					case "exception":
+						if name, ok := doc.TokenAfter(i, TOKEN_ID); ok {
+							doc.Define(DocSymbol{Name: name.Value, Kind: "exception", Detail: "exception " + name.Value, Token: name, Start: token, Stop: name, Scope: current(), Body: -1})
+						}
+					case "import":
+						path, ok := doc.TokenAfter(i, TOKEN_STRING)
+						if !ok {
+							break
+						}
+						symbol := DocSymbol{Name: ModuleName(path.Value), Kind: "module", Detail: "import " + strconv.Quote(path.Value), Path: path.Value, Token: path, Start: token, Stop: path, Scope: current(), Body: -1}
+						if as, ok := doc.TokenAfter(i+1, TOKEN_ID); ok && as.Value == "as" {
+							if name, ok := doc.TokenAfter(i+2, TOKEN_ID); ok {
+								symbol.Name, symbol.Token, symbol.Stop = name.Value, name, name
+								symbol.Detail += " as " + name.Value
+							}
+						}
+						doc.Define(symbol)
+				}
+			case TOKEN_EQUALS:
+				if name, ok := doc.TokenAfter(i, TOKEN_ID); ok {
+					doc.Define(DocSymbol{Name: name.Value, Kind: "variable", Detail: "-> " + name.Value, Token: name, Start: token, Stop: name, Scope: current(), Body: -1})
+				}
+			case TOKEN_DO:
+				if pending == -2 {
+					frames = append(frames, -1)
+					break
+				}
+				doc.Scopes = append(doc.Scopes, DocScope{Parent: current(), Start: token, Symbol: pending})
+				if pending >= 0 {
+					doc.Symbols[pending].Body = len(doc.Scopes)-1
+				}
+				frames = append(frames, len(doc.Scopes)-1)
+				pending = -2
+			case TOKEN_L_BRACKET, TOKEN_L_SQUARE: frames = append(frames, -1)
+			case TOKEN_END, TOKEN_R_BRACKET, TOKEN_R_SQUARE, TOKEN_ELIF, TOKEN_EXCEPT: pop(token)
+		}
+	}
+}
+
+func (doc *Document) Define(symbol DocSymbol) int {
+	doc.Symbols = append(doc.Symbols, symbol)
+	return len(doc.Symbols)-1
+}
+
+// Before reports whether the token starts at or before the line and
+// column.
+func (token DocToken) Before(line int, column int) bool {
+	return token.Line < line || token.Line == line && token.Column <= column
+}
+
+func (doc *Document) Contains(scope int, line int, column int) bool {
+	if scope == 0 {
+		return true
+	}
+	start, stop := doc.Scopes[scope].Start, doc.Scopes[scope].Stop
+	return start.Before(line, column) && (stop.Line == 0 || !stop.Before(line, column-1))
+}
+
+// ScopeAt returns the innermost scope around the line and column: of
+// the scopes around it, the one that starts last.
+func (doc *Document) ScopeAt(line int, column int) int {
+	innermost := 0
+	for i := 1; i < len(doc.Scopes); i++ {
+		if doc.Contains(i, line, column) {
+			innermost = i
+		}
+	}
+	return innermost
+}
+
+// TokenAt returns the token under the line and column, or the one that
+// ends right before it.
+func (doc *Document) TokenAt(line int, column int) (DocToken, bool) {
+	for i := 0; i < len(doc.Tokens); i++ {
+		token := doc.Tokens[i]
+		if token.Line == line && token.Column <= column && column < token.End {
+			return token, true
+		}
+	}
+	for i := 0; i < len(doc.Tokens); i++ {
+		if doc.Tokens[i].Line == line && doc.Tokens[i].End == column {
+			return doc.Tokens[i], true
+		}
+	}
+	return DocToken{}, false
+}
+
+// Lookup finds the symbol a name at the line and column refers to. The
+// scopes are searched from the innermost; in a scope, the last
+// definition before the name wins, or else the first one, as blocks
+// can be called before they are defined.
+func (doc *Document) Lookup(name string, line int, column int) (DocSymbol, bool) {
+	for scope := doc.ScopeAt(line, column); scope >= 0; scope = doc.Scopes[scope].Parent {
+		found := -1
+		for i := 0; i < len(doc.Symbols); i++ {
+			symbol := doc.Symbols[i]
+			if symbol.Scope != scope || symbol.Name != name {
+				continue
+			}
+			if found == -1 || symbol.Token.Before(line, column) {
+				found = i
+			}
+		}
+		if found >= 0 {
+			return doc.Symbols[found], true
+		}
+	}
+	return DocSymbol{}, false
+}
+
+// Visible returns the symbols that can be used at the line and column,
+// one for each name, the innermost first.
+func (doc *Document) Visible(line int, column int) []DocSymbol {
+	seen := map[string]bool{}
+	symbols := []DocSymbol{}
+	for scope := doc.ScopeAt(line, column); scope >= 0; scope = doc.Scopes[scope].Parent {
+		for i := 0; i < len(doc.Symbols); i++ {
+			if doc.Symbols[i].Scope == scope && !seen[doc.Symbols[i].Name] {
+				seen[doc.Symbols[i].Name] = true
+				symbols = append(symbols, doc.Symbols[i])
+			}
+		}
+	}
+	return symbols
+}
+
+// NameAt splits the name under the line and column into the module and
+// the member when it is `module.member` and the member is under the
+// column, or returns the name alone.
+func (doc *Document) NameAt(line int, column int) (string, string, DocToken, bool) {
+	token, ok := doc.TokenAt(line, column)
+	if !ok || token.Type != TOKEN_ID {
+		return "", "", token, false
+	}
+	name := token.Value
+	if index := strings.Index(name, "."); index >= 0 {
+		if column-token.Column > index {
+			return name[:index], name[index+1:], token, true
+		}
+		return name[:index], "", token, true
+	}
+	return name, "", token, true
+}
+
+// Module reads the document of the module a symbol imports. Its path
+// is empty for a module of the standard library, which is not on disk.
+func (doc *Document) Module(symbol DocSymbol) (*Document, bool) {
+	if symbol.Kind != "module" {
+		return nil, false
+	}
+	scope := InitScope()
+	scope.Modules.Root = filepath.Dir(doc.Path)
+	path, _, err := scope.ResolveImport(symbol.Path, NodePosition{doc.Path, symbol.Token.Line, symbol.Token.Column})
+	if err != nil {
+		return nil, false
+	}
+	if IsStdlibFile(path) {
+		source, ok := StdlibSource(path)
+		if !ok {
+			return nil, false
+		}
+		module := NewDocument("", source)
+		module.Path = path
+		return module, true
+	}
+	source, ReadErr := os.ReadFile(path)
+	if ReadErr != nil {
+		return nil, false
+	}
+	return NewDocument(PathToURI(path), string(source)), true
+}
+
+// Member returns the top-level symbol of a module the document imports.
+func (doc *Document) Member(name string, member string, line int, column int) (*Document, DocSymbol, bool) {
+	symbol, ok := doc.Lookup(name, line, column)
+	if !ok {
+		return nil, DocSymbol{}, false
+	}
+	module, ok := doc.Module(symbol)
+	if !ok {
+		return nil, DocSymbol{}, false
+	}
+	for i := 0; i < len(module.Symbols); i++ {
+		if module.Symbols[i].Scope == 0 && module.Symbols[i].Name == member {
+			return module, module.Symbols[i], true
+		}
+	}
+	return nil, DocSymbol{}, false
+}
+
+// -------- protocol --------
+
+type LSPPosition struct {
+	Line int `json:"line"`
+	Character int `json:"character"`
+}
+
+type LSPRange struct {
+	Start LSPPosition `json:"start"`
+	End LSPPosition `json:"end"`
+}
+
+type LSPLocation struct {
+	URI string `json:"uri"`
+	Range LSPRange `json:"range"`
+}
+
+type LSPDiagnostic struct {
+	Range LSPRange `json:"range"`
+	Severity int `json:"severity"`
+	Source string `json:"source"`
+	Message string `json:"message"`
+}
+
+type LSPMarkup struct {
+	Kind string `json:"kind"`
+	Value string `json:"value"`
+}
+
+type LSPHover struct {
+	Contents LSPMarkup `json:"contents"`
+	Range LSPRange `json:"range"`
+}
+
+type LSPCompletionItem struct {
+	Label string `json:"label"`
+	Kind int `json:"kind"`
+	Detail string `json:"detail,omitempty"`
+	Documentation string `json:"documentation,omitempty"`
+}
+
+type LSPDocumentSymbol struct {
+	Name string `json:"name"`
+	Detail string `json:"detail,omitempty"`
+	Kind int `json:"kind"`
+	Range LSPRange `json:"range"`
+	SelectionRange LSPRange `json:"selectionRange"`
+	Children []LSPDocumentSymbol `json:"children,omitempty"`
+}
+
+// LSPMessage is a request, a response or a notification. ID is missing
+// in a notification.
+type LSPMessage struct {
+	JSONRPC string `json:"jsonrpc"`
+	ID *json.RawMessage `json:"id,omitempty"`
+	Method string `json:"method,omitempty"`
+	Params json.RawMessage `json:"params,omitempty"`
+}
+
+type LSPError struct {
+	Code int `json:"code"`
+	Message string `json:"message"`
+}
+
+// LSPParams has the fields of the parameters of the methods the server
+// knows.
+type LSPParams struct {
+	TextDocument struct {
+		URI string `json:"uri"`
+		Text string `json:"text"`
+	} `json:"textDocument"`
+	Position LSPPosition `json:"position"`
+	ContentChanges []struct {
+		Text string `json:"text"`
+	} `json:"contentChanges"`
+}
+
+const (
+	LSPParseError = -32700
+	LSPMethodNotFound = -32601
+	LSPInvalidParams = -32602
+)
+
+// Completion item kinds, and symbol kinds, of the protocol.
+const (
+	CompletionFunction = 3
+	CompletionVariable = 6
+	CompletionClass = 7
+	CompletionModule = 9
+	CompletionKeyword = 14
+	SymbolModule = 2
+	SymbolClass = 5
+	SymbolFunction = 12
+	SymbolVariable = 13
+)
+
+// ReadLSPMessage reads the content of a message: headers, an empty line,
+// and Content-Length bytes.
+func ReadLSPMessage(reader *bufio.Reader) ([]byte, error) {
+	length := -1
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = strings.TrimRight(line, "\r\n")
+		if line == "" {
+			break
+		}
+		if index := strings.Index(line, ":"); index >= 0 && strings.EqualFold(strings.TrimSpace(line[:index]), "Content-Length") {
+			if length, err = strconv.Atoi(strings.TrimSpace(line[index+1:])); err != nil {
+				return nil, fmt.Errorf("invalid Content-Length `%s`", line[index+1:])
+			}
+		}
+	}
+	if length < 0 {
+		return nil, fmt.Errorf("missing Content-Length")
+	}
+	content := make([]byte, length)
+	if _, err := io.ReadFull(reader, content); err != nil {
+		return nil, err
+	}
+	return content, nil
+}
+
+func WriteLSPMessage(out io.Writer, message interface{}) error {
+	content, err := json.Marshal(message)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "Content-Length: %d\r\n\r\n%s", len(content), content)
+	return err
+}
+
+// LanguageServer speaks the Language Server Protocol. Documents are the
+// files open in the editor, by URI; the editor sends their whole text
+// on each change.
+type LanguageServer struct {
+	Documents map[string]*Document
+	Out io.Writer
+	ShutDown bool
+}
+
+// ServeLSP runs a language server on in and out until the client asks it
+// to exit, and returns the exit status: 0 after a `shutdown`, else 1.
+func ServeLSP(in io.Reader, out io.Writer) int {
+	server := &LanguageServer{Documents: map[string]*Document{}, Out: out}
+	reader := bufio.NewReader(in)
+	for {
+		content, err := ReadLSPMessage(reader)
+		if err != nil {
+			if server.ShutDown {
+				return 0
+			}
+			return 1
+		}
+		var message LSPMessage
+		if err := json.Unmarshal(content, &message); err != nil {
+			server.Respond(nil, nil, &LSPError{LSPParseError, err.Error()})
+			continue
+		}
+		if message.Method == "exit" {
+			if server.ShutDown {
+				return 0
+			}
+			return 1
+		}
+		result, RPCErr := server.Handle(message.Method, message.Params, message.ID != nil)
+		if message.ID != nil {
+			server.Respond(message.ID, result, RPCErr)
+		}
+	}
+}
+
+func (server *LanguageServer) Respond(id *json.RawMessage, result interface{}, err *LSPError) {
+	response := map[string]interface{}{"jsonrpc": "2.0", "id": id}
+	if err != nil {
+		response["error"] = err
+	} else {
+		response["result"] = result
+	}
+	WriteLSPMessage(server.Out, response)
+}
+
+func (server *LanguageServer) Notify(method string, params interface{}) {
+	WriteLSPMessage(server.Out, map[string]interface{}{"jsonrpc": "2.0", "method": method, "params": params})
+}
+
+// Handle runs a method. An unknown request is an error; an unknown
+// notification is left alone.
+func (server *LanguageServer) Handle(method string, raw json.RawMessage, request bool) (interface{}, *LSPError) {
+	var params LSPParams
+	if len(raw) != 0 {
+		if err := json.Unmarshal(raw, &params); err != nil {
+			return nil, &LSPError{LSPInvalidParams, err.Error()}
+		}
+	}
+	uri := params.TextDocument.URI
+	doc := server.Documents[uri]
+	switch method {
+		case "initialize":
+			return map[string]interface{}{
+				"capabilities": map[string]interface{}{
+					"textDocumentSync": 1,
+					"hoverProvider": true,
+					"definitionProvider": true,
+					"completionProvider": map[string]interface{}{"triggerCharacters": []string{"."}},
+					"documentSymbolProvider": true,
+				},
+				"serverInfo": map[string]string{"name": "tsh"},
+			}, nil
+		case "shutdown":
+			server.ShutDown = true
+			return nil, nil
+		case "textDocument/didOpen":
+			server.Open(uri, params.TextDocument.Text)
+		case "textDocument/didChange":
+			if len(params.ContentChanges) != 0 {
+				server.Open(uri, params.ContentChanges[len(params.ContentChanges)-1].Text)
+			}
+		case "textDocument/didClose":
+			delete(server.Documents, uri)
+			server.Notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": []LSPDiagnostic{}})
+		case "textDocument/hover":
+			if doc != nil {
+				if hover, ok := doc.Hover(params.Position); ok {
+					return hover, nil
+				}
+			}
+			return nil, nil
+		case "textDocument/definition":
+			if doc != nil {
+				if location, ok := doc.Definition(params.Position); ok {
+					return location, nil
+				}
+			}
+			return nil, nil
+		case "textDocument/completion":
+			if doc == nil {
+				return []LSPCompletionItem{}, nil
+			}
+			return doc.Completion(params.Position), nil
+		case "textDocument/documentSymbol":
+			if doc == nil {
+				return []LSPDocumentSymbol{}, nil
+			}
+			return doc.DocumentSymbols(), nil
+		default:
+			if request {
+				return nil, &LSPError{LSPMethodNotFound, fmt.Sprintf("method `%s` not found", method)}
+			}
+	}
+	return nil, nil
+}
+
+// Open reads the new text of a document and publishes its diagnostics.
+func (server *LanguageServer) Open(uri string, text string) {
+	doc := NewDocument(uri, text)
+	server.Documents[uri] = doc
+	server.Notify("textDocument/publishDiagnostics", map[string]interface{}{"uri": uri, "diagnostics": doc.Diagnostics()})
+}
+
+// Diagnostics are the syntax error of the document, or what `tsh check`
+// finds in it.
+func (doc *Document) Diagnostics() []LSPDiagnostic {
+	var errors []*Error
+	parser, err := ParserInit(LexerInit(strings.NewReader(doc.Text), doc.Path))
+	if err == nil {
+		var ast AST
+		if ast, err = ParserParse(parser); err == nil && parser.current_token_type != TOKEN_EOF {
+			err = UnexpectedTokenError(parser)
+		}
+		if err == nil {
+			errors = Check(ast)
+		}
+	}
+	if err != nil {
+		errors = []*Error{err}
+	}
+	diagnostics := []LSPDiagnostic{}
+	for i := 0; i < len(errors); i++ {
+		line, column := errors[i].Position.Line, errors[i].Position.Column
+		if line < 1 {
+			line = 1
+		}
+		if column < 1 {
+			column = 1
+		}
+		rng := LSPRange{doc.LSPPosition(line, column), doc.LSPPosition(line, column+1)}
+		for j := 0; j < len(doc.Tokens); j++ {
+			if doc.Tokens[j].Line == line && doc.Tokens[j].Column <= column && column < doc.Tokens[j].End {
+				rng = doc.TokenRange(doc.Tokens[j])
+				break
+			}
+		}
+		diagnostics = append(diagnostics, LSPDiagnostic{rng, 1, "tsh", fmt.Sprintf("%s: %s", errors[i].Type, errors[i].Text())})
+	}
+	return diagnostics
+}
+
+// LSPPosition turns a line and a column counted in characters from 1
+// into a position of the protocol, counted in UTF-16 code units from 0.
+func (doc *Document) LSPPosition(line int, column int) LSPPosition {
+	units := 0
+	runes := []rune(doc.Line(line))
+	for i := 0; i < column-1 && i < len(runes); i++ {
+		units++
+		if runes[i] >= 0x10000 {
+			units++
+		}
+	}
+	return LSPPosition{line-1, units}
+}
+
+// At turns a position of the protocol into a line and a column.
+func (doc *Document) At(position LSPPosition) (int, int) {
+	units := 0
+	runes := []rune(doc.Line(position.Line+1))
+	column := 1
+	for column-1 < len(runes) && units < position.Character {
+		units++
+		if runes[column-1] >= 0x10000 {
+			units++
+		}
+		column++
+	}
+	return position.Line+1, column
+}
+
+func (doc *Document) TokenRange(token DocToken) LSPRange {
+	return LSPRange{doc.LSPPosition(token.Line, token.Column), doc.LSPPosition(token.Line, token.End)}
+}
+
+func (doc *Document) SymbolRange(symbol DocSymbol) LSPRange {
+	return LSPRange{doc.LSPPosition(symbol.Start.Line, symbol.Start.Column), doc.LSPPosition(symbol.Stop.Line, symbol.Stop.End)}
+}
+
+// Hover shows the stack effect of a built-in word, or the definition of
+// a name.
+func (doc *Document) Hover(position LSPPosition) (LSPHover, bool) {
+	line, column := doc.At(position)
+	name, member, token, ok := doc.NameAt(line, column)
+	if !ok {
+		return LSPHover{}, false
+	}
+	if builtin, ok := Builtins[token.Value]; ok {
+		text := fmt.Sprintf("```\n%s ( %s )\n```\n%s", token.Value, strings.TrimSpace(builtin.Effect), builtin.Description)
+		return LSPHover{LSPMarkup{"markdown", text}, doc.TokenRange(token)}, true
+	}
+	var symbol DocSymbol
+	if member != "" {
+		_, symbol, ok = doc.Member(name, member, line, column)
+	} else {
+		symbol, ok = doc.Lookup(name, line, column)
+	}
+	if !ok {
+		return LSPHover{}, false
+	}
+	return LSPHover{LSPMarkup{"markdown", fmt.Sprintf("```\n%s\n```", symbol.Detail)}, doc.TokenRange(token)}, true
+}
+
+// Definition finds where the name under the position is defined: a
+// block, a variable, an exception, a module, or a member of a module
+// on disk.
+func (doc *Document) Definition(position LSPPosition) (LSPLocation, bool) {
+	line, column := doc.At(position)
+	name, member, _, ok := doc.NameAt(line, column)
+	if !ok {
+		return LSPLocation{}, false
+	}
+	if member != "" {
+		module, symbol, ok := doc.Member(name, member, line, column)
+		if !ok || module.URI == "" {
+			return LSPLocation{}, false
+		}
+		return LSPLocation{module.URI, module.TokenRange(symbol.Token)}, true
+	}
+	symbol, ok := doc.Lookup(name, line, column)
+	if !ok {
+		return LSPLocation{}, false
+	}
+	return LSPLocation{doc.URI, doc.TokenRange(symbol.Token)}, true
+}
+
+// Completion offers the built-in words, the keywords and the names that
+// can be used at the position, or the members of a module after its
+// name and a `.`.
+func (doc *Document) Completion(position LSPPosition) []LSPCompletionItem {
+	line, column := doc.At(position)
+	runes := []rune(doc.Line(line))
+	start := column-1
+	if start > len(runes) {
+		start = len(runes)
+	}
+	end := start
+	for start > 0 && (unicode.IsLetter(runes[start-1]) || unicode.IsDigit(runes[start-1]) || strings.ContainsRune("_-.", runes[start-1])) {
+		start--
+	}
+	items := []LSPCompletionItem{}
+	if prefix := string(runes[start:end]); strings.Contains(prefix, ".") {
+		symbol, ok := doc.Lookup(prefix[:strings.Index(prefix, ".")], line, column)
+		if !ok {
+			return items
+		}
+		module, ok := doc.Module(symbol)
+		if !ok {
+			return items
+		}
+		for i := 0; i < len(module.Symbols); i++ {
+			if module.Symbols[i].Scope == 0 && module.Symbols[i].Kind != "module" {
+				items = append(items, SymbolItem(module.Symbols[i]))
+			}
+		}
+		return items
+	}
+	symbols := doc.Visible(line, column)
+	for i := 0; i < len(symbols); i++ {
+		items = append(items, SymbolItem(symbols[i]))
+	}
+	names := make([]string, 0, len(Builtins))
+	for name := range Builtins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for i := 0; i < len(names); i++ {
+		builtin := Builtins[names[i]]
+		items = append(items, LSPCompletionItem{names[i], CompletionFunction, "( " + strings.TrimSpace(builtin.Effect) + " )", builtin.Description})
+	}
+	for i := 0; i < len(Keywords); i++ {
+		items = append(items, LSPCompletionItem{Label: Keywords[i], Kind: CompletionKeyword})
+	}
+	for i := 1; i < BuiltinErrorCount; i++ {
+		if ErrorNames[i] != "" {
+			items = append(items, LSPCompletionItem{Label: ErrorNames[i], Kind: CompletionClass})
+		}
+	}
+	return items
+}
+
+func SymbolItem(symbol DocSymbol) LSPCompletionItem {
+	kind := CompletionVariable
+	switch symbol.Kind {
+		case "block": kind = CompletionFunction
+		case "exception": kind = CompletionClass
+		case "module": kind = CompletionModule
+	}
+	return LSPCompletionItem{Label: symbol.Name, Kind: kind, Detail: symbol.Detail}
+}
+
+// DocumentSymbols are the blocks, variables, exceptions and modules of
+// the document. The names a block defines are its children; a variable
+// defined again is listed once.
+func (doc *Document) DocumentSymbols() []LSPDocumentSymbol {
+	// the block whose children the names of a scope are, or -1
+	owner := func(scope int) int {
+		for ; scope > 0; scope = doc.Scopes[scope].Parent {
+			if doc.Scopes[scope].Symbol >= 0 {
+				return doc.Scopes[scope].Symbol
+			}
+		}
+		return -1
+	}
+	children := map[int][]int{}
+	seen := map[string]bool{}
+	for i := 0; i < len(doc.Symbols); i++ {
+		parent := owner(doc.Symbols[i].Scope)
+		key := fmt.Sprintf("%d %s %s", parent, doc.Symbols[i].Kind, doc.Symbols[i].Name)
+		if doc.Symbols[i].Kind == "variable" && seen[key] {
+			continue
+		}
+		seen[key] = true
+		children[parent] = append(children[parent], i)
+	}
+	var build func(parent int) []LSPDocumentSymbol
+	build = func(parent int) []LSPDocumentSymbol {
+		symbols := []LSPDocumentSymbol{}
+		for _, i := range children[parent] {
+			symbol := doc.Symbols[i]
+			kind := SymbolVariable
+			switch symbol.Kind {
+				case "block": kind = SymbolFunction
+				case "exception": kind = SymbolClass
+				case "module": kind = SymbolModule
+			}
+			item := LSPDocumentSymbol{symbol.Name, symbol.Detail, kind, doc.SymbolRange(symbol), doc.TokenRange(symbol.Token), nil}
+			if symbol.Kind == "block" {
+				item.Children = build(i)
+			}
+			symbols = append(symbols, item)
+		}
+		return symbols
+	}
+	return build(-1)
+}
+
+// URIToPath returns the path of a `file:` URI, or the URI.
+func URIToPath(uri string) string {
+	u, err := url.Parse(uri)
+	if err != nil || u.Scheme != "file" {
+		return uri
+	}
+	path := u.Path
+	// `/C:/dir` on Windows
+	if len(path) >= 3 && path[0] == '/' && path[2] == ':' {
+		path = path[1:]
+	}
+	return filepath.FromSlash(path)
+}
+
+func PathToURI(path string) string {
+	if abs, err := filepath.Abs(path); err == nil {
+		path = abs
+	}
+	path = filepath.ToSlash(path)
+	if !strings.HasPrefix(path, "/") {
+		path = "/" + path
+	}
+	return (&url.URL{Scheme: "file", Path: path}).String()
+}
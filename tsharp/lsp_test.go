@@ -0,0 +1,215 @@
+package tsharp
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// The columns of the tokens are those of the text, after strings and
+// characters out of the ASCII range too.
+func TestTokenize(t *testing.T) {
+	text := "\"a\" 'b' x\n\"é😀\" \"\\t\" -> y  # c\nblock f do \"s\" end 1.5"
+	doc := NewDocument("file:///test.tsp", text)
+	want := []string{"\"a\"", "'b'", "x", "\"é😀\"", "\"\\t\"", "->", "y", "block", "f", "do", "\"s\"", "end", "1.5"}
+	if len(doc.Tokens) != len(want) {
+		t.Fatalf("got %d tokens, want %d: %+v", len(doc.Tokens), len(want), doc.Tokens)
+	}
+	for i, token := range doc.Tokens {
+		runes := []rune(doc.Line(token.Line))
+		if token.Column < 1 || token.End > len(runes)+1 || string(runes[token.Column-1:token.End-1]) != want[i] {
+			t.Errorf("token %d %q: got columns %d to %d", i, want[i], token.Column, token.End)
+		}
+	}
+}
+
+func TestLSPPosition(t *testing.T) {
+	doc := NewDocument("file:///test.tsp", "x\n\"😀\" y")
+	tests := []struct {
+		line int
+		column int
+		position LSPPosition
+	}{
+		{1, 1, LSPPosition{0, 0}},
+		{2, 1, LSPPosition{1, 0}},
+		{2, 3, LSPPosition{1, 3}},
+		{2, 5, LSPPosition{1, 5}},
+	}
+	for _, test := range tests {
+		if got := doc.LSPPosition(test.line, test.column); got != test.position {
+			t.Errorf("%d:%d: got %+v, want %+v", test.line, test.column, got, test.position)
+		}
+		if line, column := doc.At(test.position); line != test.line || column != test.column {
+			t.Errorf("%+v: got %d:%d, want %d:%d", test.position, line, column, test.line, test.column)
+		}
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	tests := []struct {
+		text string
+		message string
+		rng LSPRange
+	}{
+		{"1 2 +\n", "", LSPRange{}},
+		{"\"a\" \"b\" end", "SyntaxError", LSPRange{LSPPosition{0, 8}, LSPPosition{0, 11}}},
+		{"\"é\" 'b' inc", "TypeError", LSPRange{LSPPosition{0, 8}, LSPPosition{0, 11}}},
+		{"\"a\" ->", "SyntaxError", LSPRange{LSPPosition{0, 4}, LSPPosition{0, 6}}},
+	}
+	for _, test := range tests {
+		diagnostics := NewDocument("file:///test.tsp", test.text).Diagnostics()
+		if test.message == "" {
+			if len(diagnostics) != 0 {
+				t.Errorf("%q: got %+v, want none", test.text, diagnostics)
+			}
+			continue
+		}
+		if len(diagnostics) != 1 || !strings.HasPrefix(diagnostics[0].Message, test.message) || diagnostics[0].Range != test.rng {
+			t.Errorf("%q: got %+v, want a %s at %+v", test.text, diagnostics, test.message, test.rng)
+		}
+	}
+}
+
+func TestHoverAndDefinition(t *testing.T) {
+	text := "block sq ( int -- int ) do\n    dup * -> n n\nend\n\"x\" drop 3 sq"
+	doc := NewDocument("file:///test.tsp", text)
+	hover, ok := doc.Hover(LSPPosition{1, 5})
+	if !ok || !strings.Contains(hover.Contents.Value, "dup (") || hover.Range != (LSPRange{LSPPosition{1, 4}, LSPPosition{1, 7}}) {
+		t.Errorf("hover on dup: got %+v, %v", hover, ok)
+	}
+	hover, ok = doc.Hover(LSPPosition{3, 12})
+	if !ok || !strings.Contains(hover.Contents.Value, "block sq ( int -- int )") {
+		t.Errorf("hover on sq: got %+v, %v", hover, ok)
+	}
+	location, ok := doc.Definition(LSPPosition{3, 12})
+	if !ok || location.Range != (LSPRange{LSPPosition{0, 6}, LSPPosition{0, 8}}) {
+		t.Errorf("definition of sq: got %+v, %v", location, ok)
+	}
+	location, ok = doc.Definition(LSPPosition{1, 16})
+	if !ok || location.Range != (LSPRange{LSPPosition{1, 13}, LSPPosition{1, 14}}) {
+		t.Errorf("definition of n: got %+v, %v", location, ok)
+	}
+	if _, ok := doc.Definition(LSPPosition{3, 1}); ok {
+		t.Errorf("a string has no definition")
+	}
+}
+
+func TestCompletion(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFile(t, filepath.Join(dir, "lib.tsp"), "block twice do 2 * end\n3 -> three\n")
+	text := "import \"lib\"\nblock f do\n    0 -> local\n    \nend\nlib.\n"
+	doc := NewDocument(PathToURI(filepath.Join(dir, "main.tsp")), text)
+	labels := func(items []LSPCompletionItem) map[string]bool {
+		found := map[string]bool{}
+		for _, item := range items {
+			if item.Label == "" {
+				t.Errorf("an item has no label: %+v", item)
+			}
+			found[item.Label] = true
+		}
+		return found
+	}
+	inside := labels(doc.Completion(LSPPosition{3, 4}))
+	for _, label := range []string{"f", "local", "lib", "dup", "block", "TypeError"} {
+		if !inside[label] {
+			t.Errorf("inside f: %q is missing", label)
+		}
+	}
+	if outside := labels(doc.Completion(LSPPosition{5, 0})); outside["local"] {
+		t.Errorf("outside f: the variable of f is offered")
+	}
+	members := labels(doc.Completion(LSPPosition{5, 4}))
+	if len(members) != 2 || !members["twice"] || !members["three"] {
+		t.Errorf("members of lib: got %v", members)
+	}
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	text := "exception Bad\n1 -> x\nblock f do\n    2 -> y\n    2 -> y\nend\n"
+	symbols := NewDocument("file:///test.tsp", text).DocumentSymbols()
+	got := []string{}
+	for _, symbol := range symbols {
+		names := []string{}
+		for _, child := range symbol.Children {
+			names = append(names, child.Name)
+		}
+		got = append(got, fmt.Sprintf("%s%v", symbol.Name, names))
+	}
+	if strings.Join(got, " ") != "Bad[] x[] f[y]" {
+		t.Errorf("got %v", got)
+	}
+	if symbols[2].Range != (LSPRange{LSPPosition{2, 0}, LSPPosition{5, 3}}) || symbols[2].SelectionRange != (LSPRange{LSPPosition{2, 6}, LSPPosition{2, 7}}) {
+		t.Errorf("the ranges of f: got %+v", symbols[2])
+	}
+}
+
+// A session: the server answers the requests, publishes the diagnostics
+// of the documents, and exits with 0 after a shutdown.
+func TestServeLSP(t *testing.T) {
+	var in bytes.Buffer
+	messages := []string{
+		`{"jsonrpc":"2.0","id":1,"method":"initialize","params":{}}`,
+		`{"jsonrpc":"2.0","method":"textDocument/didOpen","params":{"textDocument":{"uri":"file:///a.tsp","text":"\"a\" inc"}}}`,
+		`{"jsonrpc":"2.0","id":2,"method":"textDocument/hover","params":{"textDocument":{"uri":"file:///a.tsp"},"position":{"line":0,"character":5}}}`,
+		`{"jsonrpc":"2.0","id":3,"method":"unknown"}`,
+		`{"jsonrpc":"2.0","id":4,"method":"shutdown"}`,
+		`{"jsonrpc":"2.0","method":"exit"}`,
+	}
+	for _, message := range messages {
+		fmt.Fprintf(&in, "Content-Length: %d\r\n\r\n%s", len(message), message)
+	}
+	var out bytes.Buffer
+	if status := ServeLSP(&in, &out); status != 0 {
+		t.Errorf("got exit status %d, want 0", status)
+	}
+	reader := bufio.NewReader(&out)
+	replies := []map[string]interface{}{}
+	for {
+		content, err := ReadLSPMessage(reader)
+		if err != nil {
+			break
+		}
+		var reply map[string]interface{}
+		if err := json.Unmarshal(content, &reply); err != nil {
+			t.Fatal(err)
+		}
+		replies = append(replies, reply)
+	}
+	if len(replies) != 5 {
+		t.Fatalf("got %d messages, want 5: %v", len(replies), replies)
+	}
+	if _, ok := replies[0]["result"].(map[string]interface{})["capabilities"]; !ok {
+		t.Errorf("initialize: got %v", replies[0])
+	}
+	diagnostics := replies[1]["params"].(map[string]interface{})["diagnostics"].([]interface{})
+	if replies[1]["method"] != "textDocument/publishDiagnostics" || len(diagnostics) != 1 {
+		t.Errorf("didOpen: got %v", replies[1])
+	}
+	if hover := fmt.Sprint(replies[2]["result"]); !strings.Contains(hover, "inc (") {
+		t.Errorf("hover: got %v", replies[2])
+	}
+	if code := replies[3]["error"].(map[string]interface{})["code"]; code != float64(LSPMethodNotFound) {
+		t.Errorf("unknown method: got %v", replies[3])
+	}
+	if replies[4]["result"] != nil {
+		t.Errorf("shutdown: got %v", replies[4])
+	}
+	if ServeLSP(strings.NewReader(""), &out) != 1 {
+		t.Errorf("an exit without a shutdown should give 1")
+	}
+}
+
+func TestURIToPath(t *testing.T) {
+	path := filepath.Join(os.TempDir(), "a b.tsp")
+	if got := URIToPath(PathToURI(path)); got != path {
+		t.Errorf("got %q, want %q", got, path)
+	}
+	if got := URIToPath("untitled:1"); got != "untitled:1" {
+		t.Errorf("got %q", got)
+	}
+}
@@ -0,0 +1,819 @@
+package tsharp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+)
+
+
+// -----------------------------
+// --------- Manifest ----------
+// -----------------------------
+
+const (
+	ManifestFile = "tsh.mod"
+	LockFile = "tsh.lock"
+)
+
+// ManifestField is one `key: value` of a manifest or lock file. The value
+// is a string, or a block of fields when Fields is not nil.
+type ManifestField struct {
+	Key string
+	Value string
+	Fields []ManifestField
+	Position NodePosition
+}
+
+// Manifest is a parsed tsh.mod. Main is the file run by `import "name"`
+// when the project is a dependency.
+type Manifest struct {
+	Name string
+	Description string
+	Version string
+	License string
+	RepoURL string
+	Main string
+	Dependencies []Dependency
+	Dir string
+}
+
+// Dependency is an entry of `dependencies`: a directory or a .tar.gz,
+// .tgz or .zip archive, relative to the directory of the manifest, or for
+// a manifest in an archive, to the directory of the archive.
+type Dependency struct {
+	Name string
+	Source string
+	Position NodePosition
+}
+
+// Locked is a dependency resolved by `tsh mod tidy`. Source is relative
+// to the directory of the lock file, and Sum is the sha256 of the archive,
+// or of the files of the directory.
+type Locked struct {
+	Name string
+	Version string
+	Source string
+	Sum string
+}
+
+// Package is a dependency that can be imported: `import "name"` runs Main
+// in Root, and `import "name/file"` runs file in Root.
+type Package struct {
+	Name string
+	Root string
+	Main string
+}
+
+func ManifestErr(position NodePosition, format string, args ...interface{}) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: %s", position.FileName, position.Line, position.Column, fmt.Sprintf(format, args...))
+	err.Type = SyntaxError
+	err.Position = position
+	return &err
+}
+
+type manifestLexer struct {
+	source []rune
+	index int
+	position NodePosition
+}
+
+func (lexer *manifestLexer) Skip() {
+	for lexer.index < len(lexer.source) {
+		char := lexer.source[lexer.index]
+		if char == '#' || (char == '/' && lexer.index+1 < len(lexer.source) && lexer.source[lexer.index+1] == '/') {
+			for lexer.index < len(lexer.source) && lexer.source[lexer.index] != '\n' {
+				lexer.Next()
+			}
+		} else if unicode.IsSpace(char) || char == ',' {
+			lexer.Next()
+		} else {
+			return
+		}
+	}
+}
+
+func (lexer *manifestLexer) Next() rune {
+	char := lexer.source[lexer.index]
+	lexer.index++
+	if char == '\n' {
+		lexer.position.Line++
+		lexer.position.Column = 1
+	} else {
+		lexer.position.Column++
+	}
+	return char
+}
+
+// Token returns the next token: a name, a quoted string, or one of
+// `{`, `}` and `:`. kind is 'a' for names, 's' for strings and 0 at the end.
+func (lexer *manifestLexer) Token() (rune, string, NodePosition, *Error) {
+	lexer.Skip()
+	position := lexer.position
+	if lexer.index >= len(lexer.source) {
+		return 0, "", position, nil
+	}
+	char := lexer.source[lexer.index]
+	switch {
+		case char == '{' || char == '}' || char == ':':
+			lexer.Next()
+			return char, string(char), position, nil
+		case char == '\'' || char == '"':
+			lexer.Next()
+			text := []rune{}
+			for lexer.index < len(lexer.source) && lexer.source[lexer.index] != char && lexer.source[lexer.index] != '\n' {
+				text = append(text, lexer.Next())
+			}
+			if lexer.index >= len(lexer.source) || lexer.source[lexer.index] != char {
+				return 0, "", position, ManifestErr(position, "string is not closed.")
+			}
+			lexer.Next()
+			return 's', string(text), position, nil
+		case unicode.IsLetter(char) || char == '_':
+			text := []rune{}
+			for lexer.index < len(lexer.source) && (unicode.IsLetter(lexer.source[lexer.index]) || unicode.IsDigit(lexer.source[lexer.index]) || strings.ContainsRune("_-", lexer.source[lexer.index])) {
+				text = append(text, lexer.Next())
+			}
+			return 'a', string(text), position, nil
+	}
+	return 0, "", position, ManifestErr(position, "unexpected character `%c`.", char)
+}
+
+// ParseManifestFields parses `Title { key: value ... }`, where a value is a
+// string or a block of fields, and returns the title and the fields.
+func ParseManifestFields(source string, FileName string) (string, []ManifestField, *Error) {
+	lexer := &manifestLexer{source: []rune(source), position: NodePosition{FileName, 1, 1}}
+	kind, title, position, err := lexer.Token()
+	if err != nil {
+		return "", nil, err
+	}
+	if kind != 'a' {
+		return "", nil, ManifestErr(position, "expected a name like `Module`.")
+	}
+	if kind, text, position, err := lexer.Token(); err != nil {
+		return "", nil, err
+	} else if kind != '{' {
+		return "", nil, ManifestErr(position, "expected `{`, but got `%s`.", text)
+	}
+	fields, err := lexer.Fields()
+	if err != nil {
+		return "", nil, err
+	}
+	if kind, text, position, err := lexer.Token(); err != nil {
+		return "", nil, err
+	} else if kind != 0 {
+		return "", nil, ManifestErr(position, "unexpected `%s` after `}`.", text)
+	}
+	return title, fields, nil
+}
+
+func (lexer *manifestLexer) Fields() ([]ManifestField, *Error) {
+	fields := []ManifestField{}
+	for {
+		kind, key, position, err := lexer.Token()
+		if err != nil {
+			return nil, err
+		}
+		switch kind {
+			case '}':
+				return fields, nil
+			case 0:
+				return nil, ManifestErr(position, "`{` is not closed.")
+			case 'a', 's':
+			default:
+				return nil, ManifestErr(position, "expected a key, but got `%s`.", key)
+		}
+		if kind, text, position, err := lexer.Token(); err != nil {
+			return nil, err
+		} else if kind != ':' {
+			return nil, ManifestErr(position, "expected `:` after `%s`, but got `%s`.", key, text)
+		}
+		kind, value, ValuePosition, err := lexer.Token()
+		if err != nil {
+			return nil, err
+		}
+		field := ManifestField{Key: key, Position: position}
+		switch kind {
+			case 's':
+				field.Value = value
+			case '{':
+				if field.Fields, err = lexer.Fields(); err != nil {
+					return nil, err
+				}
+			default:
+				return nil, ManifestErr(ValuePosition, "expected a string or `{` after `%s:`.", key)
+		}
+		fields = append(fields, field)
+	}
+}
+
+// ParseManifest reads the tsh.mod at path.
+func ParseManifest(path string) (*Manifest, *Error) {
+	source, ReadErr := os.ReadFile(path)
+	if ReadErr != nil {
+		return nil, ImportErr(NodePosition{path, 1, 1}, "cannot read the manifest: %s.", ReadErr)
+	}
+	_, fields, err := ParseManifestFields(string(source), path)
+	if err != nil {
+		return nil, err
+	}
+	manifest := &Manifest{Dir: filepath.Dir(path)}
+	for i := 0; i < len(fields); i++ {
+		field := fields[i]
+		if field.Key == "dependencies" {
+			if field.Fields == nil {
+				return nil, ManifestErr(field.Position, "`dependencies` is a block of `name: 'path'`.")
+			}
+			for j := 0; j < len(field.Fields); j++ {
+				dep := field.Fields[j]
+				if dep.Fields != nil || dep.Value == "" {
+					return nil, ManifestErr(dep.Position, "dependency `%s` needs the path of a directory or an archive.", dep.Key)
+				}
+				if !IsPackageName(dep.Key) {
+					return nil, ManifestErr(dep.Position, "invalid dependency name `%s`.", dep.Key)
+				}
+				for k := 0; k < len(manifest.Dependencies); k++ {
+					if manifest.Dependencies[k].Name == dep.Key {
+						return nil, ManifestErr(dep.Position, "dependency `%s` is declared twice.", dep.Key)
+					}
+				}
+				manifest.Dependencies = append(manifest.Dependencies, Dependency{dep.Key, dep.Value, dep.Position})
+			}
+			continue
+		}
+		var value *string
+		switch field.Key {
+			case "name": value = &manifest.Name
+			case "description": value = &manifest.Description
+			case "version": value = &manifest.Version
+			case "license": value = &manifest.License
+			case "repo_url": value = &manifest.RepoURL
+			case "main": value = &manifest.Main
+			default:
+				return nil, ManifestErr(field.Position, "unknown field `%s`.", field.Key)
+		}
+		if field.Fields != nil {
+			return nil, ManifestErr(field.Position, "`%s` is a string.", field.Key)
+		}
+		*value = field.Value
+	}
+	return manifest, nil
+}
+
+func IsPackageName(name string) bool {
+	if name == "" || unicode.IsDigit([]rune(name)[0]) {
+		return false
+	}
+	for _, char := range name {
+		if !unicode.IsLetter(char) && !unicode.IsDigit(char) && char != '_' && char != '-' {
+			return false
+		}
+	}
+	return true
+}
+
+func QuoteManifest(value string) string {
+	if strings.Contains(value, "'") {
+		return "\"" + value + "\""
+	}
+	return "'" + value + "'"
+}
+
+// Format writes the manifest back in the layout of `tsh mod init`.
+func (manifest *Manifest) Format() string {
+	var out strings.Builder
+	out.WriteString("Module {\n")
+	fields := [][2]string{
+		{"name", manifest.Name},
+		{"description", manifest.Description},
+		{"version", manifest.Version},
+		{"license", manifest.License},
+		{"repo_url", manifest.RepoURL},
+		{"main", manifest.Main},
+	}
+	for i := 0; i < len(fields); i++ {
+		if fields[i][1] != "" || i < 3 {
+			out.WriteString(fmt.Sprintf("\t%s: %s\n", fields[i][0], QuoteManifest(fields[i][1])))
+		}
+	}
+	out.WriteString("\tdependencies: {\n")
+	for i := 0; i < len(manifest.Dependencies); i++ {
+		out.WriteString(fmt.Sprintf("\t\t%s: %s\n", manifest.Dependencies[i].Name, QuoteManifest(manifest.Dependencies[i].Source)))
+	}
+	out.WriteString("\t}\n}\n")
+	return out.String()
+}
+
+// FindManifest returns the path of the tsh.mod in dir or the nearest
+// directory above it, or "" when there is none.
+func FindManifest(dir string) string {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for {
+		path := filepath.Join(dir, ManifestFile)
+		if info, err := os.Stat(path); err == nil && !info.IsDir() {
+			return path
+		}
+		parent := filepath.Dir(dir)
+		if parent == dir {
+			return ""
+		}
+		dir = parent
+	}
+}
+
+// InitManifest writes a tsh.mod for a new project in dir.
+func InitManifest(dir string, name string) (string, *Error) {
+	path := filepath.Join(dir, ManifestFile)
+	position := NodePosition{path, 1, 1}
+	if _, err := os.Stat(path); err == nil {
+		return path, ImportErr(position, "%s already exists.", ManifestFile)
+	}
+	if name == "" {
+		if abs, err := filepath.Abs(dir); err == nil {
+			name = filepath.Base(abs)
+		}
+	}
+	manifest := &Manifest{Name: name, Version: "0.1.0", Main: "main.tsp"}
+	if err := os.WriteFile(path, []byte(manifest.Format()), 0644); err != nil {
+		return path, ImportErr(position, "cannot write the manifest: %s.", err)
+	}
+	return path, nil
+}
+
+
+// -----------------------------
+// ---------- Resolve ----------
+// -----------------------------
+
+// CacheDir is where archives are unpacked: TSH_CACHE, or `tsh` in the
+// user cache directory.
+func CacheDir() string {
+	if dir := os.Getenv("TSH_CACHE"); dir != "" {
+		return dir
+	}
+	if dir, err := os.UserCacheDir(); err == nil {
+		return filepath.Join(dir, "tsh")
+	}
+	return filepath.Join(os.TempDir(), "tsh-cache")
+}
+
+func IsArchive(path string) bool {
+	return strings.HasSuffix(path, ".tar.gz") || strings.HasSuffix(path, ".tgz") || strings.HasSuffix(path, ".zip")
+}
+
+// HashFile is the sum of an archive.
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	hash := sha256.New()
+	if _, err := io.Copy(hash, file); err != nil {
+		return "", err
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// HashDir is the sum of the names and contents of the files in a
+// directory, without the lock file.
+func HashDir(dir string) (string, error) {
+	files := []string{}
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if !info.IsDir() && info.Name() != LockFile {
+			files = append(files, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	sort.Strings(files)
+	hash := sha256.New()
+	for i := 0; i < len(files); i++ {
+		name, _ := filepath.Rel(dir, files[i])
+		data, err := os.ReadFile(files[i])
+		if err != nil {
+			return "", err
+		}
+		fmt.Fprintf(hash, "%s\x00%d\x00", filepath.ToSlash(name), len(data))
+		hash.Write(data)
+	}
+	return "sha256:" + hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Unpack extracts the archive with the given sum into the cache, once,
+// and returns the root of the package in it. An archive with a single
+// directory at the top has its root there.
+func Unpack(archive string, sum string) (string, error) {
+	dir := filepath.Join(CacheDir(), "pkg", strings.TrimPrefix(sum, "sha256:"))
+	if _, err := os.Stat(dir); err != nil {
+		if err := os.MkdirAll(filepath.Dir(dir), 0755); err != nil {
+			return "", err
+		}
+		tmp, err := os.MkdirTemp(filepath.Dir(dir), "unpack-")
+		if err != nil {
+			return "", err
+		}
+		defer os.RemoveAll(tmp)
+		if strings.HasSuffix(archive, ".zip") {
+			err = UnpackZip(archive, tmp)
+		} else {
+			err = UnpackTar(archive, tmp)
+		}
+		if err != nil {
+			return "", err
+		}
+		if err := os.Rename(tmp, dir); err != nil && !os.IsExist(err) {
+			if _, statErr := os.Stat(dir); statErr != nil {
+				return "", err
+			}
+		}
+	}
+	return PackageRoot(dir), nil
+}
+
+func PackageRoot(dir string) string {
+	entries, err := os.ReadDir(dir)
+	if err == nil && len(entries) == 1 && entries[0].IsDir() {
+		return filepath.Join(dir, entries[0].Name())
+	}
+	return dir
+}
+
+// ArchivePath checks that a name in an archive stays in the directory it
+// is unpacked to.
+func ArchivePath(dir string, name string) (string, error) {
+	path := filepath.Join(dir, filepath.FromSlash(name))
+	if path != dir && !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+		return "", fmt.Errorf("invalid path `%s` in the archive", name)
+	}
+	return path, nil
+}
+
+func WriteArchiveFile(path string, reader io.Reader) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	file, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+func UnpackTar(archive string, dir string) error {
+	file, err := os.Open(archive)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	gz, err := gzip.NewReader(file)
+	if err != nil {
+		return err
+	}
+	reader := tar.NewReader(gz)
+	for {
+		header, err := reader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		path, err := ArchivePath(dir, header.Name)
+		if err != nil {
+			return err
+		}
+		switch header.Typeflag {
+			case tar.TypeDir:
+				err = os.MkdirAll(path, 0755)
+			case tar.TypeReg:
+				err = WriteArchiveFile(path, reader)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func UnpackZip(archive string, dir string) error {
+	reader, err := zip.OpenReader(archive)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+	for i := 0; i < len(reader.File); i++ {
+		entry := reader.File[i]
+		path, err := ArchivePath(dir, entry.Name)
+		if err != nil {
+			return err
+		}
+		if entry.FileInfo().IsDir() {
+			if err := os.MkdirAll(path, 0755); err != nil {
+				return err
+			}
+			continue
+		}
+		file, err := entry.Open()
+		if err != nil {
+			return err
+		}
+		err = WriteArchiveFile(path, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Resolver resolves the dependencies of a manifest and of its
+// dependencies. A name is one package: two different sources for it are
+// an error. The old lock lets an archive that is gone be found in the
+// cache by its sum.
+type Resolver struct {
+	Dir string
+	Old map[string]Locked
+	Locked []Locked
+	Packages map[string]Package
+}
+
+// Resolve resolves the dependencies of the manifest in dir, and returns
+// what to write to the lock file and the packages to import.
+func Resolve(manifest *Manifest, old []Locked) ([]Locked, map[string]Package, *Error) {
+	resolver := &Resolver{Dir: manifest.Dir, Old: map[string]Locked{}, Packages: map[string]Package{}}
+	for i := 0; i < len(old); i++ {
+		resolver.Old[old[i].Name] = old[i]
+	}
+	if err := resolver.Add(manifest); err != nil {
+		return nil, nil, err
+	}
+	sort.Slice(resolver.Locked, func(i, j int) bool { return resolver.Locked[i].Name < resolver.Locked[j].Name })
+	return resolver.Locked, resolver.Packages, nil
+}
+
+func (resolver *Resolver) Add(manifest *Manifest) (*Error) {
+	for i := 0; i < len(manifest.Dependencies); i++ {
+		dep := manifest.Dependencies[i]
+		source := dep.Source
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(manifest.Dir, source)
+		}
+		if rel, err := filepath.Rel(resolver.Dir, source); err == nil {
+			source = rel
+		}
+		source = filepath.ToSlash(source)
+		if _, ok := resolver.Packages[dep.Name]; ok {
+			for j := 0; j < len(resolver.Locked); j++ {
+				if resolver.Locked[j].Name == dep.Name && resolver.Locked[j].Source != source {
+					return ImportErr(dep.Position, "dependency `%s` is required from both `%s` and `%s`.", dep.Name, resolver.Locked[j].Source, source)
+				}
+			}
+			continue
+		}
+		locked, root, err := resolver.Fetch(dep, source)
+		if err != nil {
+			return err
+		}
+		sub, err := resolver.AddPackage(locked, root)
+		if err != nil {
+			return err
+		}
+		if sub != nil {
+			if IsArchive(source) {
+				// an archive's own dependencies sit next to it
+				sub.Dir = filepath.Dir(filepath.Join(resolver.Dir, filepath.FromSlash(source)))
+				if filepath.IsAbs(filepath.FromSlash(source)) {
+					sub.Dir = filepath.Dir(filepath.FromSlash(source))
+				}
+			}
+			if err := resolver.Add(sub); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// Fetch finds the files of a dependency: a directory in place, or an
+// archive unpacked into the cache.
+func (resolver *Resolver) Fetch(dep Dependency, source string) (Locked, string, *Error) {
+	path := filepath.Join(resolver.Dir, filepath.FromSlash(source))
+	if filepath.IsAbs(filepath.FromSlash(source)) {
+		path = filepath.FromSlash(source)
+	}
+	locked := Locked{Name: dep.Name, Source: source}
+	info, StatErr := os.Stat(path)
+	if !IsArchive(source) {
+		if StatErr != nil || !info.IsDir() {
+			return locked, "", ImportErr(dep.Position, "dependency `%s`: directory `%s` not found.", dep.Name, source)
+		}
+		sum, err := HashDir(path)
+		if err != nil {
+			return locked, "", ImportErr(dep.Position, "dependency `%s`: %s.", dep.Name, err)
+		}
+		locked.Sum = sum
+		return locked, path, nil
+	}
+	if StatErr != nil {
+		// the archive is gone, but it may still be in the cache
+		old, ok := resolver.Old[dep.Name]
+		if ok && old.Source == source {
+			dir := filepath.Join(CacheDir(), "pkg", strings.TrimPrefix(old.Sum, "sha256:"))
+			if _, err := os.Stat(dir); err == nil {
+				locked.Sum = old.Sum
+				return locked, PackageRoot(dir), nil
+			}
+		}
+		return locked, "", ImportErr(dep.Position, "dependency `%s`: archive `%s` not found, and not in the cache.", dep.Name, source)
+	}
+	sum, err := HashFile(path)
+	if err != nil {
+		return locked, "", ImportErr(dep.Position, "dependency `%s`: %s.", dep.Name, err)
+	}
+	locked.Sum = sum
+	root, err := Unpack(path, sum)
+	if err != nil {
+		return locked, "", ImportErr(dep.Position, "dependency `%s`: cannot unpack `%s`: %s.", dep.Name, source, err)
+	}
+	return locked, root, nil
+}
+
+// AddPackage records a fetched dependency, and returns its own manifest
+// if it has one.
+func (resolver *Resolver) AddPackage(locked Locked, root string) (*Manifest, *Error) {
+	pkg := Package{Name: locked.Name, Root: root, Main: "main.tsp"}
+	var manifest *Manifest
+	if path := filepath.Join(root, ManifestFile); FileExists(path) {
+		var err *Error
+		if manifest, err = ParseManifest(path); err != nil {
+			return nil, err
+		}
+		locked.Version = manifest.Version
+		if manifest.Main != "" {
+			pkg.Main = manifest.Main
+		}
+	}
+	resolver.Locked = append(resolver.Locked, locked)
+	resolver.Packages[locked.Name] = pkg
+	return manifest, nil
+}
+
+func FileExists(path string) bool {
+	info, err := os.Stat(path)
+	return err == nil && !info.IsDir()
+}
+
+
+// -----------------------------
+// ------------ Lock -----------
+// -----------------------------
+
+// ReadLock reads a tsh.lock. A missing lock file has no entries.
+func ReadLock(path string) ([]Locked, *Error) {
+	source, ReadErr := os.ReadFile(path)
+	if os.IsNotExist(ReadErr) {
+		return nil, nil
+	}
+	if ReadErr != nil {
+		return nil, ImportErr(NodePosition{path, 1, 1}, "cannot read the lock file: %s.", ReadErr)
+	}
+	_, fields, err := ParseManifestFields(string(source), path)
+	if err != nil {
+		return nil, err
+	}
+	locks := []Locked{}
+	for i := 0; i < len(fields); i++ {
+		locked := Locked{Name: fields[i].Key}
+		for j := 0; j < len(fields[i].Fields); j++ {
+			switch fields[i].Fields[j].Key {
+				case "version": locked.Version = fields[i].Fields[j].Value
+				case "source": locked.Source = fields[i].Fields[j].Value
+				case "sum": locked.Sum = fields[i].Fields[j].Value
+			}
+		}
+		if locked.Source == "" || locked.Sum == "" {
+			return nil, ManifestErr(fields[i].Position, "`%s` needs a source and a sum.", locked.Name)
+		}
+		locks = append(locks, locked)
+	}
+	return locks, nil
+}
+
+func FormatLock(locks []Locked) string {
+	var out strings.Builder
+	out.WriteString("# Written by `tsh mod tidy`, do not edit.\nLock {\n")
+	for i := 0; i < len(locks); i++ {
+		out.WriteString(fmt.Sprintf("\t%s: {\n", locks[i].Name))
+		if locks[i].Version != "" {
+			out.WriteString(fmt.Sprintf("\t\tversion: %s\n", QuoteManifest(locks[i].Version)))
+		}
+		out.WriteString(fmt.Sprintf("\t\tsource: %s\n", QuoteManifest(locks[i].Source)))
+		out.WriteString(fmt.Sprintf("\t\tsum: %s\n", QuoteManifest(locks[i].Sum)))
+		out.WriteString("\t}\n")
+	}
+	out.WriteString("}\n")
+	return out.String()
+}
+
+// Tidy resolves the dependencies of the project of the manifest at path
+// and writes its lock file.
+func Tidy(path string) ([]Locked, *Error) {
+	manifest, err := ParseManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	LockPath := filepath.Join(manifest.Dir, LockFile)
+	old, err := ReadLock(LockPath)
+	if err != nil {
+		return nil, err
+	}
+	locks, _, err := Resolve(manifest, old)
+	if err != nil {
+		return nil, err
+	}
+	if WriteErr := os.WriteFile(LockPath, []byte(FormatLock(locks)), 0644); WriteErr != nil {
+		return nil, ImportErr(NodePosition{LockPath, 1, 1}, "cannot write the lock file: %s.", WriteErr)
+	}
+	return locks, nil
+}
+
+// LoadPackages makes the dependencies in the lock file of the project at
+// path importable. A directory is used as it is now; an archive must
+// still have the sum in the lock, and only an archive that is gone is
+// taken from the cache by its sum. Every dependency of the manifest must
+// be in the lock.
+func LoadPackages(path string) (map[string]Package, *Error) {
+	manifest, err := ParseManifest(path)
+	if err != nil {
+		return nil, err
+	}
+	LockPath := filepath.Join(manifest.Dir, LockFile)
+	locks, err := ReadLock(LockPath)
+	if err != nil {
+		return nil, err
+	}
+	resolver := &Resolver{Dir: manifest.Dir, Packages: map[string]Package{}}
+	for i := 0; i < len(manifest.Dependencies); i++ {
+		found := false
+		for j := 0; j < len(locks); j++ {
+			found = found || locks[j].Name == manifest.Dependencies[i].Name
+		}
+		if !found {
+			return nil, ImportErr(manifest.Dependencies[i].Position, "dependency `%s` is not in %s, run `tsh mod tidy`.", manifest.Dependencies[i].Name, LockFile)
+		}
+	}
+	for i := 0; i < len(locks); i++ {
+		locked := locks[i]
+		position := NodePosition{LockPath, 1, 1}
+		source := filepath.FromSlash(locked.Source)
+		if !filepath.IsAbs(source) {
+			source = filepath.Join(manifest.Dir, source)
+		}
+		root := source
+		if IsArchive(locked.Source) {
+			sum, HashErr := HashFile(source)
+			cached := filepath.Join(CacheDir(), "pkg", strings.TrimPrefix(locked.Sum, "sha256:"))
+			if HashErr == nil {
+				if sum != locked.Sum {
+					return nil, ImportErr(position, "dependency `%s`: archive `%s` does not match its sum in %s, run `tsh mod tidy`.", locked.Name, locked.Source, LockFile)
+				}
+				var UnpackErr error
+				if root, UnpackErr = Unpack(source, sum); UnpackErr != nil {
+					return nil, ImportErr(position, "dependency `%s`: cannot unpack `%s`: %s.", locked.Name, locked.Source, UnpackErr)
+				}
+			} else if _, err := os.Stat(cached); err == nil {
+				root = PackageRoot(cached)
+			} else {
+				return nil, ImportErr(position, "dependency `%s`: archive `%s` not found, and not in the cache.", locked.Name, locked.Source)
+			}
+		} else if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			return nil, ImportErr(position, "dependency `%s`: directory `%s` not found.", locked.Name, locked.Source)
+		}
+		if _, err := resolver.AddPackage(locked, root); err != nil {
+			return nil, err
+		}
+	}
+	return resolver.Packages, nil
+}
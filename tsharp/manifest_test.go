@@ -0,0 +1,218 @@
+package tsharp
+
+import (
+	"archive/tar"
+	"archive/zip"
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func WriteTestFile(t *testing.T, path string, source string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// WriteTestTar writes a .tar.gz with the given files, by name.
+func WriteTestTar(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	gz := gzip.NewWriter(file)
+	writer := tar.NewWriter(gz)
+	for name, source := range files {
+		writer.WriteHeader(&tar.Header{Name: name, Mode: 0644, Size: int64(len(source)), Typeflag: tar.TypeReg})
+		writer.Write([]byte(source))
+	}
+	writer.Close()
+	gz.Close()
+}
+
+func WriteTestZip(t *testing.T, path string, files map[string]string) {
+	t.Helper()
+	file, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	writer := zip.NewWriter(file)
+	for name, source := range files {
+		entry, _ := writer.Create(name)
+		entry.Write([]byte(source))
+	}
+	writer.Close()
+}
+
+func TestParseManifest(t *testing.T) {
+	path := filepath.Join(t.TempDir(), ManifestFile)
+	WriteTestFile(t, path, "Module {\n\tname: 'shapes'\n\tversion: \"1.0\" // comment\n\tmain: 'lib.tsp'\n\tdependencies: {\n\t\tgeometry: '../geometry', util: 'util.zip'\n\t}\n}\n")
+	manifest, err := ParseManifest(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if manifest.Name != "shapes" || manifest.Version != "1.0" || manifest.Main != "lib.tsp" || len(manifest.Dependencies) != 2 {
+		t.Fatalf("got %+v", manifest)
+	}
+	if dep := manifest.Dependencies[1]; dep.Name != "util" || dep.Source != "util.zip" {
+		t.Errorf("got dependency %+v", dep)
+	}
+	again := filepath.Join(t.TempDir(), ManifestFile)
+	WriteTestFile(t, again, manifest.Format())
+	if formatted, err := ParseManifest(again); err != nil || formatted.Format() != manifest.Format() {
+		t.Errorf("the formatted manifest reads back as %+v, %v", formatted, err)
+	}
+	tests := []struct {
+		source string
+		message string
+	}{
+		{"Module { name: 'a' ", "`{` is not closed."},
+		{"Module { name: 'a }", "string is not closed."},
+		{"Module { colour: 'red' }", "unknown field `colour`."},
+		{"Module { name: { a: 'b' } }", "`name` is a string."},
+		{"Module { dependencies: { a: 'x' a: 'y' } }", "dependency `a` is declared twice."},
+		{"Module { dependencies: { 'a b': 'x' } }", "invalid dependency name `a b`."},
+		{"Module { dependencies: 'x' }", "`dependencies` is a block of `name: 'path'`."},
+		{"Module { name 'a' }", "expected `:` after `name`, but got `a`."},
+		{"Module { } }", "unexpected `}` after `}`."},
+	}
+	for _, test := range tests {
+		WriteTestFile(t, path, test.source)
+		_, err := ParseManifest(path)
+		if err == nil || err.Type != SyntaxError || err.Text() != test.message {
+			t.Errorf("%q: got %v, want a SyntaxError %q", test.source, err, test.message)
+		}
+	}
+}
+
+func TestReadLock(t *testing.T) {
+	path := filepath.Join(t.TempDir(), LockFile)
+	locks, err := ReadLock(path)
+	if err != nil || len(locks) != 0 {
+		t.Fatalf("a missing lock file: got %v, %v", locks, err)
+	}
+	want := []Locked{{"geometry", "1.0", "../geometry", "sha256:aa"}, {"util", "", "util.zip", "sha256:bb"}}
+	WriteTestFile(t, path, FormatLock(want))
+	locks, err = ReadLock(path)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(locks) != len(want) || locks[0] != want[0] || locks[1] != want[1] {
+		t.Errorf("got %+v, want %+v", locks, want)
+	}
+	WriteTestFile(t, path, "Lock { util: { source: 'util.zip' } }")
+	if _, err := ReadLock(path); err == nil || err.Text() != "`util` needs a source and a sum." {
+		t.Errorf("got %v, want an error for the missing sum", err)
+	}
+}
+
+func TestArchivePath(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "pkg")
+	for _, name := range []string{"main.tsp", "lib/a.tsp", "./lib/../main.tsp"} {
+		if path, err := ArchivePath(dir, name); err != nil || !strings.HasPrefix(path, dir+string(filepath.Separator)) {
+			t.Errorf("%q: got %q, %v", name, path, err)
+		}
+	}
+	for _, name := range []string{"../evil.tsp", "lib/../../evil.tsp", "../pkg-evil/a.tsp"} {
+		if path, err := ArchivePath(dir, name); err == nil {
+			t.Errorf("%q: got %q, want an error", name, path)
+		}
+	}
+}
+
+// An entry that leaves the directory stops the unpacking, and nothing is
+// written outside of it.
+func TestUnpackEscape(t *testing.T) {
+	dir := t.TempDir()
+	archives := map[string]func(string){
+		"evil.tar.gz": func(path string) { WriteTestTar(t, path, map[string]string{"../evil.tsp": "1"}) },
+		"evil.zip": func(path string) { WriteTestZip(t, path, map[string]string{"../evil.tsp": "1"}) },
+	}
+	for name, write := range archives {
+		archive := filepath.Join(dir, name)
+		write(archive)
+		out := filepath.Join(dir, "out", name)
+		os.MkdirAll(out, 0755)
+		var err error
+		if strings.HasSuffix(name, ".zip") {
+			err = UnpackZip(archive, out)
+		} else {
+			err = UnpackTar(archive, out)
+		}
+		if err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+		if FileExists(filepath.Join(dir, "out", "evil.tsp")) {
+			t.Errorf("%s: a file was written outside of the directory", name)
+		}
+	}
+}
+
+func TestLoadPackages(t *testing.T) {
+	t.Setenv("TSH_CACHE", t.TempDir())
+	dir := t.TempDir()
+	archive := filepath.Join(dir, "geometry.tar.gz")
+	WriteTestTar(t, archive, map[string]string{"geometry/main.tsp": "block area do * end\n"})
+	WriteTestFile(t, filepath.Join(dir, "util", "main.tsp"), "block twice do 2 * end\n")
+	manifest := filepath.Join(dir, ManifestFile)
+	WriteTestFile(t, manifest, "Module {\n\tname: 'app'\n\tdependencies: {\n\t\tgeometry: 'geometry.tar.gz'\n\t\tutil: 'util'\n\t}\n}\n")
+	if _, err := LoadPackages(manifest); err == nil || !strings.Contains(err.Text(), "run `tsh mod tidy`") {
+		t.Errorf("without a lock: got %v, want an ImportError", err)
+	}
+	locks, err := Tidy(manifest)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if len(locks) != 2 || locks[0].Name != "geometry" || !strings.HasPrefix(locks[0].Sum, "sha256:") {
+		t.Fatalf("got locks %+v", locks)
+	}
+	packages, err := LoadPackages(manifest)
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	if pkg := packages["geometry"]; !FileExists(filepath.Join(pkg.Root, pkg.Main)) {
+		t.Errorf("got package %+v", pkg)
+	}
+	if pkg := packages["util"]; pkg.Root != filepath.Join(dir, "util") {
+		t.Errorf("got package %+v", pkg)
+	}
+	// the cache is only used when the archive is gone, so a changed
+	// archive does not match its sum even though it is in the cache
+	WriteTestTar(t, archive, map[string]string{"geometry/main.tsp": "block area do + end\n"})
+	if _, err := LoadPackages(manifest); err == nil || !strings.Contains(err.Text(), "does not match its sum") {
+		t.Errorf("a changed archive: got %v, want an ImportError", err)
+	}
+	os.Remove(archive)
+	if packages, err := LoadPackages(manifest); err != nil || !FileExists(filepath.Join(packages["geometry"].Root, "main.tsp")) {
+		t.Errorf("an archive that is gone: got %+v, %v, want the cached package", packages, err)
+	}
+}
+
+// The manifest is read by the first import that is not next to the
+// importing file, so a broken one only stops the scripts that need it.
+func TestManifestIsLoadedOnImport(t *testing.T) {
+	dir := t.TempDir()
+	WriteTestFile(t, filepath.Join(dir, ManifestFile), "Module {")
+	WriteTestFile(t, filepath.Join(dir, "lib.tsp"), "block twice do 2 * end\n")
+	WriteTestFile(t, filepath.Join(dir, "main.tsp"), "import \"lib\"\n21 lib.twice\n")
+	WriteTestFile(t, filepath.Join(dir, "package.tsp"), "import \"geometry\"\n")
+	for _, engine := range Engines {
+		stack, err := NewTestInterpreter(engine.Engine).RunFile(filepath.Join(dir, "main.tsp"))
+		if err != nil || FormatStack(stack) != "[42]" {
+			t.Errorf("%s: got %s, %v, want [42]", engine.Name, FormatStack(stack), err)
+		}
+		_, err = NewTestInterpreter(engine.Engine).RunFile(filepath.Join(dir, "package.tsp"))
+		if e, ok := err.(*Error); !ok || e.Type != SyntaxError || e.Position.FileName != filepath.Join(dir, ManifestFile) {
+			t.Errorf("%s: got %v, want the SyntaxError of the manifest", engine.Name, err)
+		}
+	}
+}
@@ -0,0 +1,730 @@
+package tsharp
+
+import (
+	"fmt"
+	"math/big"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+
+// -----------------------------
+// ---------- Parser -----------
+// -----------------------------
+
+type Parser struct {
+	current_token_type Token
+	current_token_value string
+	FileName string
+	lexer Lexer
+	line int
+	column int
+}
+
+
+func ParserInit(lexer *Lexer) (*Parser, *Error) {
+	pos, tok, val, file, err := lexer.Lex()
+	if err != nil {
+		return nil, err
+	}
+	return &Parser{
+		current_token_type: tok,
+		current_token_value: val,
+		FileName: file,
+		lexer: *lexer,
+		line: pos.line,
+		column: pos.column,
+	}, nil
+}
+
+func (parser *Parser) ParserEat(token Token) (*Error) {
+	if token != parser.current_token_type {
+		return UnexpectedTokenError(parser)
+	}
+	pos, tok, val, file, err := parser.lexer.Lex()
+	if err != nil {
+		return err
+	}
+	parser.current_token_type = tok
+	parser.current_token_value = val
+	parser.FileName = file
+	parser.line = pos.line
+	parser.column = pos.column
+	return nil
+}
+
+// ModuleName is the name `import` binds a module to: the file name
+// without its directory and extension.
+func ModuleName(path string) string {
+	name := filepath.Base(path)
+	if index := strings.Index(name, "."); index > 0 {
+		name = name[:index]
+	}
+	return name
+}
+
+// ParserEatName eats the name a statement defines. A name with a `.`
+// reads a module member, so it cannot be defined.
+func ParserEatName(parser *Parser) (string, *Error) {
+	name := parser.current_token_value
+	if parser.current_token_type == TOKEN_ID && strings.Contains(name, ".") {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: cannot define `%s`, a name with a `.` is a module member.", parser.FileName, parser.line, parser.column, name)
+		err.Type = SyntaxError
+		err.Position = RetNodePosition(parser)
+		return "", &err
+	}
+	if err := parser.ParserEat(TOKEN_ID); err != nil {
+		return "", err
+	}
+	return name, nil
+}
+
+func UnexpectedTokenError(parser *Parser) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: unexpected token value `%s`.", parser.FileName, parser.line, parser.column, parser.current_token_value)
+	err.Type = SyntaxError
+	err.Position = RetNodePosition(parser)
+	return &err
+}
+
+func StrToInt(num string) (int, error) {
+	return strconv.Atoi(num)
+}
+
+func RetNodePosition(parser *Parser) NodePosition {
+	return NodePosition{
+		Line: parser.line,
+		Column: parser.column,
+		FileName: parser.FileName,
+	}
+}
+
+// ParserParseVar parses a name, which is `module.member` for a name in
+// an imported module.
+func ParserParseVar(parser *Parser) (Var, *Error) {
+	name := parser.current_token_value
+	member := ""
+	if index := strings.Index(name, "."); index >= 0 {
+		name, member = name[:index], name[index+1:]
+		if name == "" || strings.HasSuffix(member, ".") || strings.Contains(member, "..") || member == "" {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: invalid name `%s`.", parser.FileName, parser.line, parser.column, parser.current_token_value)
+			err.Type = SyntaxError
+			err.Position = RetNodePosition(parser)
+			return Var{}, &err
+		}
+	}
+	VarExpr := Var {
+		Name: name,
+		Position: RetNodePosition(parser),
+		Member: member,
+	}
+	if err := parser.ParserEat(TOKEN_ID); err != nil {
+		return Var{}, err
+	}
+	return VarExpr, nil
+}
+
+func ParserParseError(parser *Parser) (AST, *Error) {
+	if parser.current_token_type == TOKEN_TYPE && parser.current_token_value == "error" {
+		if err := parser.ParserEat(TOKEN_TYPE); err != nil {
+			return nil, err
+		}
+		return AsType{"error"}, nil
+	}
+	if parser.current_token_type == TOKEN_ID {
+		return ParserParseVar(parser)
+	}
+	ErrorValue, _ := LookupErrorType(parser.current_token_value)
+	if err := parser.ParserEat(TOKEN_ERROR); err != nil {
+		return nil, err
+	}
+	ErrorExpr := AsError {
+		err: ErrorValue,
+	}
+	return ErrorExpr, nil
+}
+
+// ParserParseStackEffect parses a block signature such as `( int int -- int )`.
+func ParserParseStackEffect(parser *Parser) (*StackEffect, *Error) {
+	if err := parser.ParserEat(TOKEN_L_PAREN); err != nil {
+		return nil, err
+	}
+	Signature := StackEffect{
+		Inputs: []string{},
+		Outputs: []string{},
+	}
+	IsOutput := false
+	for parser.current_token_type != TOKEN_R_PAREN {
+		if parser.current_token_type == TOKEN_DOUBLE_MINUS && !IsOutput {
+			IsOutput = true
+			if err := parser.ParserEat(TOKEN_DOUBLE_MINUS); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if parser.current_token_type != TOKEN_TYPE && !(parser.current_token_type == TOKEN_ID && parser.current_token_value == "any") {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: expected a type in the stack effect, got `%s`.", parser.FileName, parser.line, parser.column, parser.current_token_value)
+			err.Type = SyntaxError
+			err.Position = RetNodePosition(parser)
+			return nil, &err
+		}
+		if IsOutput {
+			Signature.Outputs = append(Signature.Outputs, parser.current_token_value)
+		} else {
+			Signature.Inputs = append(Signature.Inputs, parser.current_token_value)
+		}
+		if err := parser.ParserEat(parser.current_token_type); err != nil {
+			return nil, err
+		}
+	}
+	if !IsOutput {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: the stack effect expected `--`.", parser.FileName, parser.line, parser.column)
+		err.Type = SyntaxError
+		err.Position = RetNodePosition(parser)
+		return nil, &err
+	}
+	if err := parser.ParserEat(TOKEN_R_PAREN); err != nil {
+		return nil, err
+	}
+	return &Signature, nil
+}
+
+func ParserParseExpr(parser *Parser) (AST, *Error) {
+	var expr AST
+	var err *Error
+	switch parser.current_token_type {
+		case TOKEN_INT:
+			IntValue, ConvErr := StrToInt(parser.current_token_value)
+			if BigValue, ok := new(big.Int).SetString(parser.current_token_value, 10); ConvErr != nil && ok {
+				expr = AsBigInt {
+					BigValue,
+				}
+				if err := parser.ParserEat(TOKEN_INT); err != nil {
+					return nil, err
+				}
+				break
+			}
+			if ConvErr != nil {
+				err := Error{}
+				err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: invalid integer literal `%s`.", parser.FileName, parser.line, parser.column, parser.current_token_value)
+				err.Type = SyntaxError
+				err.Position = RetNodePosition(parser)
+				return nil, &err
+			}
+			expr = AsInt {
+				IntValue,
+			}
+			if err := parser.ParserEat(TOKEN_INT); err != nil {
+				return nil, err
+			}
+		case TOKEN_FLOAT:
+			FloatValue, ConvErr := strconv.ParseFloat(parser.current_token_value, 64)
+			if ConvErr != nil {
+				err := Error{}
+				err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: invalid float literal `%s`.", parser.FileName, parser.line, parser.column, parser.current_token_value)
+				err.Type = SyntaxError
+				err.Position = RetNodePosition(parser)
+				return nil, &err
+			}
+			expr = AsFloat {
+				FloatValue,
+			}
+			if err := parser.ParserEat(TOKEN_FLOAT); err != nil {
+				return nil, err
+			}
+		case TOKEN_STRING:
+			expr = AsStr {
+				parser.current_token_value,
+			}
+			if err := parser.ParserEat(TOKEN_STRING); err != nil {
+				return nil, err
+			}
+		case TOKEN_BOOL:
+			BoolValue := parser.current_token_value == "true"
+			expr = AsBool {
+				BoolValue,
+			}
+			if err := parser.ParserEat(TOKEN_BOOL); err != nil {
+				return nil, err
+			}
+		case TOKEN_ERROR:
+			expr, err = ParserParseError(parser)
+			if err != nil {
+				return nil, err
+			}
+		case TOKEN_L_BRACKET:
+			if err := parser.ParserEat(TOKEN_L_BRACKET); err != nil {
+				return nil, err
+			}
+			var ListBody AST
+			if parser.current_token_type != TOKEN_R_BRACKET {
+				ListBody, err = ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+			}
+			expr = NewList {
+				ListBody,
+			}
+			if err := parser.ParserEat(TOKEN_R_BRACKET); err != nil {
+				return nil, err
+			}
+		case TOKEN_L_SQUARE:
+			position := RetNodePosition(parser)
+			if err := parser.ParserEat(TOKEN_L_SQUARE); err != nil {
+				return nil, err
+			}
+			var MapBody AST
+			if parser.current_token_type != TOKEN_R_SQUARE {
+				MapBody, err = ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+			}
+			expr = NewMap {
+				MapBody,
+				position,
+			}
+			if err := parser.ParserEat(TOKEN_R_SQUARE); err != nil {
+				return nil, err
+			}
+		case TOKEN_ID:
+			VarExpr, err := ParserParseVar(parser)
+			if err != nil {
+				return nil, err
+			}
+			expr = VarExpr
+		case TOKEN_TYPE:
+			expr = AsType {
+				parser.current_token_value,
+			}
+			if err := parser.ParserEat(TOKEN_TYPE); err != nil {
+				return nil, err
+			}
+		default:
+			return nil, UnexpectedTokenError(parser)
+	}
+
+	return expr, nil
+}
+
+// MarkTail marks the calls in tail position in a block body: the last
+// statement, and the last statement of each branch of an `if` that is
+// the last statement. Nothing of the block runs after them, so they
+// reuse the call of the block instead of nesting a new one.
+func MarkTail(body AST) {
+	if body == nil || len(body.(AsStatements)) == 0 {
+		return
+	}
+	last := len(body.(AsStatements))-1
+	node := body.(AsStatements)[last]
+	switch node.(type) {
+		case AsPush:
+			if value, ok := node.(AsPush).value.(Var); ok {
+				value.Tail = true
+				body.(AsStatements)[last] = AsPush{value: value}
+			}
+		case AsId:
+			if node.(AsId).name == "call" {
+				value := node.(AsId)
+				value.Tail = true
+				body.(AsStatements)[last] = value
+			}
+		case If:
+			MarkTail(node.(If).IfBody)
+			for i := 0; i < len(node.(If).ElifBodys); i++ {
+				MarkTail(node.(If).ElifBodys[i])
+			}
+			MarkTail(node.(If).ElseBody)
+	}
+}
+
+// ParserParseQuote parses a quotation, `block do ... end` without a
+// name, after the `block` keyword.
+func ParserParseQuote(parser *Parser, position NodePosition) (AST, *Error) {
+	var Signature *StackEffect = nil
+	if parser.current_token_type == TOKEN_L_PAREN {
+		var err *Error
+		Signature, err = ParserParseStackEffect(parser)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := parser.ParserEat(TOKEN_DO); err != nil {
+		return nil, err
+	}
+	QuoteBody, err := ParserParse(parser)
+	if err != nil {
+		return nil, err
+	}
+	MarkTail(QuoteBody)
+	if err := parser.ParserEat(TOKEN_END); err != nil {
+		return nil, err
+	}
+	return AsQuote {
+		Block: Blockdef {
+			Name: "quote",
+			Position: position,
+			Signature: Signature,
+			BlockBody: QuoteBody,
+		},
+	}, nil
+}
+
+func ParserParse(parser *Parser) (AST, *Error) {
+	var Statements AsStatements
+	if  parser.current_token_type == TOKEN_DO || parser.current_token_type == TOKEN_END || parser.current_token_type == TOKEN_ELIF || parser.current_token_type == TOKEN_ELSE || parser.current_token_type == TOKEN_EXCEPT || parser.current_token_type == TOKEN_FINALLY {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:SyntaxError:%d:%d: the body is empty, unexpected token value `%s`.", parser.FileName, parser.line, parser.column, parser.current_token_value)
+		err.Type = SyntaxError
+		err.Position = RetNodePosition(parser)
+		return nil, &err
+	}
+	for {
+		if parser.current_token_type == TOKEN_ID {
+			if IsBuiltin(parser.current_token_value) {
+				name := parser.current_token_value
+				position := RetNodePosition(parser)
+				IdExpr := AsId{
+					name: name,
+					Position: position,
+				}
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				Statements = append(Statements, IdExpr)
+			} else if parser.current_token_value == "assert" {
+				position := RetNodePosition(parser)
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				AssertExpr := Assert {
+					Position: position,
+					Message: parser.current_token_value,
+				}
+				if err := parser.ParserEat(TOKEN_STRING); err != nil {
+					return nil, err
+				}
+				Statements = append(Statements, AssertExpr)
+			} else if parser.current_token_value == "block" {
+				QuotePosition := RetNodePosition(parser)
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				if parser.current_token_type == TOKEN_DO || parser.current_token_type == TOKEN_L_PAREN {
+					quote, err := ParserParseQuote(parser, QuotePosition)
+					if err != nil {
+						return nil, err
+					}
+					Statements = append(Statements, AsPush{value: quote})
+					continue
+				}
+				position := RetNodePosition(parser)
+				name, err := ParserEatName(parser)
+				if err != nil {
+					return nil, err
+				}
+				var Signature *StackEffect = nil
+				if parser.current_token_type == TOKEN_L_PAREN {
+					var err *Error
+					Signature, err = ParserParseStackEffect(parser)
+					if err != nil {
+						return nil, err
+					}
+				}
+				if err := parser.ParserEat(TOKEN_DO); err != nil {
+					return nil, err
+				}
+				BlockBody, err := ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+				MarkTail(BlockBody)
+				if err := parser.ParserEat(TOKEN_END); err != nil {
+					return nil, err
+				}
+				BlockdefExpr := Blockdef {
+					Name: name,
+					Position: position,
+					Signature: Signature,
+					BlockBody: BlockBody,
+				}
+				Statements = append(Statements, BlockdefExpr)
+			} else if parser.current_token_value == "exception" {
+				position := RetNodePosition(parser)
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				name, err := ParserEatName(parser)
+				if err != nil {
+					return nil, err
+				}
+				ErrordefExpr := Errordef {
+					Name: name,
+					Position: position,
+				}
+				Statements = append(Statements, ErrordefExpr)
+			} else if parser.current_token_value == "import" {
+				position := RetNodePosition(parser)
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				path := parser.current_token_value
+				if err := parser.ParserEat(TOKEN_STRING); err != nil {
+					return nil, err
+				}
+				name := ModuleName(path)
+				if parser.current_token_type == TOKEN_ID && parser.current_token_value == "as" {
+					if err := parser.ParserEat(TOKEN_ID); err != nil {
+						return nil, err
+					}
+					var err *Error
+					if name, err = ParserEatName(parser); err != nil {
+						return nil, err
+					}
+				}
+				Statements = append(Statements, Import{path, name, position})
+			} else if parser.current_token_value == "include" {
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				IncludeExpr := Include {
+					parser.current_token_value,
+					RetNodePosition(parser),
+				}
+				if err := parser.ParserEat(TOKEN_STRING); err != nil {
+					return nil, err
+				}
+				Statements = append(Statements, IncludeExpr)
+			} else if parser.current_token_value == "if" {
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				position := RetNodePosition(parser)
+				IfOp, err := ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+				if err := parser.ParserEat(TOKEN_DO); err != nil {
+					return nil, err
+				}
+				IfBody, err := ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+				var ElifOps []AST
+				var ElifBodys []AST
+				var ElifPositions []NodePosition
+				for {
+					if parser.current_token_type != TOKEN_ELIF {
+						break
+					}
+					if err := parser.ParserEat(TOKEN_ELIF); err != nil {
+						return nil, err
+					}
+					ElifPosition := RetNodePosition(parser);
+					ElifOp, err := ParserParse(parser)
+					if err != nil {
+						return nil, err
+					}
+					ElifOps = append(ElifOps, ElifOp)
+					ElifPositions = append(ElifPositions, ElifPosition)
+					if err := parser.ParserEat(TOKEN_DO); err != nil {
+						return nil, err
+					}
+					ElifBody, err := ParserParse(parser)
+					if err != nil {
+						return nil, err
+					}
+					ElifBodys = append(ElifBodys, ElifBody)
+				}
+				var ElseBody AST = nil
+				for {
+					if parser.current_token_type != TOKEN_ELSE {
+						break
+					}
+					if err := parser.ParserEat(TOKEN_ELSE); err != nil {
+						return nil, err
+					}
+					ElseBody, err = ParserParse(parser)
+					if err != nil {
+						return nil, err
+					}
+				}
+				if err := parser.ParserEat(TOKEN_END); err != nil {
+					return nil, err
+				}
+				IfExpr := If {
+					IfOp: IfOp,
+					Position: position,
+					IfBody: IfBody,
+					ElifOps: ElifOps,
+					ElifPositions: ElifPositions,
+					ElifBodys: ElifBodys,
+					ElseBody: ElseBody,
+				}
+				Statements = append(Statements, IfExpr)
+			} else if parser.current_token_value == "for" {
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				position := RetNodePosition(parser);
+				ForOp, err := ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+				if err := parser.ParserEat(TOKEN_DO); err != nil {
+					return nil, err
+				}
+				ForBody, err := ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+				if err := parser.ParserEat(TOKEN_END); err != nil {
+					return nil, err
+				}
+				ForExpr := For {
+					ForOp: ForOp,
+					Position: position,
+					ForBody: ForBody,
+				}
+				Statements = append(Statements, ForExpr)
+			} else if parser.current_token_value == "try" {
+				position := RetNodePosition(parser)
+				if err := parser.ParserEat(TOKEN_ID); err != nil {
+					return nil, err
+				}
+				TryBody, err := ParserParse(parser)
+				if err != nil {
+					return nil, err
+				}
+				var ExceptErrors []AST
+				var ExceptNames []string
+				var ExceptPositions []NodePosition
+				var ExceptBodys []AST
+				for {
+					if parser.current_token_type != TOKEN_EXCEPT {
+						break
+					}
+					if err := parser.ParserEat(TOKEN_EXCEPT); err != nil {
+						return nil, err
+					}
+					ExceptError, err := ParserParseError(parser)
+					if err != nil {
+						return nil, err
+					}
+					ExceptErrors = append(ExceptErrors, ExceptError)
+					ExceptName := ""
+					ExceptPosition := NodePosition{}
+					if parser.current_token_type == TOKEN_EQUALS {
+						if err := parser.ParserEat(TOKEN_EQUALS); err != nil {
+							return nil, err
+						}
+						ExceptPosition = RetNodePosition(parser)
+						if ExceptName, err = ParserEatName(parser); err != nil {
+							return nil, err
+						}
+					}
+					ExceptNames = append(ExceptNames, ExceptName)
+					ExceptPositions = append(ExceptPositions, ExceptPosition)
+					if err := parser.ParserEat(TOKEN_DO); err != nil {
+						return nil, err
+					}
+					ExceptBody, err := ParserParse(parser)
+					if err != nil {
+						return nil, err
+					}
+					ExceptBodys = append(ExceptBodys, ExceptBody)
+				}
+				var FinallyBody AST = nil
+				if parser.current_token_type == TOKEN_FINALLY {
+					if err := parser.ParserEat(TOKEN_FINALLY); err != nil {
+						return nil, err
+					}
+					FinallyBody, err = ParserParse(parser)
+					if err != nil {
+						return nil, err
+					}
+				}
+				if err := parser.ParserEat(TOKEN_END); err != nil {
+					return nil, err
+				}
+				TryExpr := Try {
+					Position: position,
+					TryBody: TryBody,
+					ExceptErrors: ExceptErrors,
+					ExceptNames: ExceptNames,
+					ExceptPositions: ExceptPositions,
+					ExceptBodys: ExceptBodys,
+					FinallyBody: FinallyBody,
+				}
+				Statements = append(Statements, TryExpr)
+			} else {
+				expr, err := ParserParseExpr(parser)
+				if err != nil {
+					return nil, err
+				}
+				PushExpr := AsPush{
+					value: expr,
+				}
+				Statements = append(Statements, PushExpr)
+			}
+		} else if parser.current_token_type == TOKEN_INT  || parser.current_token_type == TOKEN_FLOAT || parser.current_token_type == TOKEN_STRING ||
+		    parser.current_token_type == TOKEN_BOOL || parser.current_token_type == TOKEN_ERROR || parser.current_token_type == TOKEN_L_BRACKET || parser.current_token_type == TOKEN_L_SQUARE || parser.current_token_type == TOKEN_TYPE {
+			expr, err := ParserParseExpr(parser)
+			if err != nil {
+				return nil, err
+			}
+			PushExpr := AsPush{
+				value: expr,
+			}
+			Statements = append(Statements, PushExpr)
+		} else if parser.current_token_type == TOKEN_EQUALS || parser.current_token_type == TOKEN_FAT_ARROW {
+			position := RetNodePosition(parser)
+			outer := parser.current_token_type == TOKEN_FAT_ARROW
+			if err := parser.ParserEat(parser.current_token_type); err != nil {
+				return nil, err
+			}
+			name, err := ParserEatName(parser)
+			if err != nil {
+				return nil, err
+			}
+			VardefExpr := Vardef {
+				Name: name,
+				Position: position,
+				Outer: outer,
+			}
+			Statements = append(Statements, VardefExpr)
+		} else if parser.current_token_type == TOKEN_PLUS || parser.current_token_type == TOKEN_MINUS || parser.current_token_type == TOKEN_MUL || parser.current_token_type == TOKEN_DIV || parser.current_token_type == TOKEN_REM {
+			BinopExpr := AsBinop {
+				op: uint8(parser.current_token_type),
+				Position: RetNodePosition(parser),
+			}
+			if err := parser.ParserEat(parser.current_token_type); err != nil {
+				return nil, err
+			}
+			Statements = append(Statements, BinopExpr)
+		} else if parser.current_token_type == TOKEN_LESS_EQUALS || parser.current_token_type == TOKEN_GREATER_EQUALS || parser.current_token_type == TOKEN_LESS_THAN || parser.current_token_type == TOKEN_GREATER_THAN || parser.current_token_type == TOKEN_IS_EQUALS || parser.current_token_type == TOKEN_NOT_EQUALS || parser.current_token_type == TOKEN_OR || parser.current_token_type == TOKEN_AND {
+			CompareExpr := Compare {
+				op: uint8(parser.current_token_type),
+				Position: RetNodePosition(parser),
+			}
+			if err := parser.ParserEat(parser.current_token_type); err != nil {
+				return nil, err
+			}
+			Statements = append(Statements, CompareExpr)
+		} else if parser.current_token_type == TOKEN_EOF || parser.current_token_type == TOKEN_DO ||
+		    parser.current_token_type == TOKEN_END || parser.current_token_type == TOKEN_ELIF ||
+			parser.current_token_type == TOKEN_ELSE || parser.current_token_type == TOKEN_EXCEPT || parser.current_token_type == TOKEN_FINALLY || parser.current_token_type == TOKEN_R_BRACKET || parser.current_token_type == TOKEN_R_SQUARE {
+			break
+		} else {
+			return nil, UnexpectedTokenError(parser)
+		}
+	}
+	return Statements, nil
+}
@@ -0,0 +1,149 @@
+package tsharp
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+
+// -----------------------------
+// ----------- REPL ------------
+// -----------------------------
+
+const (
+	ReplPrompt = "tsh> "
+	ReplContinue = "...  "
+)
+
+func ReplHelp(out io.Writer) {
+	fmt.Fprintln(out, ".stack        show the stack")
+	fmt.Fprintln(out, ".vars         show the variables and blocks")
+	fmt.Fprintln(out, ".clear        drop all elements of the stack")
+	fmt.Fprintln(out, ".load <file>  run a file in this session")
+	fmt.Fprintln(out, ".help         show this message")
+	fmt.Fprintln(out, ".exit         leave the REPL")
+}
+
+// Incomplete reports whether source has a `block`, `if`, `for`, `try`,
+// `{` or `[` that is not closed yet, so the REPL should read more lines.
+func Incomplete(source string) bool {
+	lexer := LexerInit(strings.NewReader(source), "<stdin>")
+	depth := 0
+	for {
+		_, tok, val, _, err := lexer.Lex()
+		if err != nil || tok == TOKEN_EOF {
+			break
+		}
+		switch tok {
+			case TOKEN_ID:
+				if val == "block" || val == "if" || val == "for" || val == "try" {
+					depth++
+				}
+			case TOKEN_L_BRACKET, TOKEN_L_SQUARE: depth++
+			case TOKEN_END, TOKEN_R_BRACKET, TOKEN_R_SQUARE: depth--
+		}
+	}
+	return depth > 0
+}
+
+func (interpreter *Interpreter) FormatStack() string {
+	return FormatStack(interpreter.Scope.Stack)
+}
+
+func FormatStack(stack []AST) string {
+	var values []string
+	for i := 0; i < len(stack); i++ {
+		values = append(values, FormatValue(stack[i], true))
+	}
+	return "[" + strings.Join(values, " ") + "]"
+}
+
+func (interpreter *Interpreter) FormatVariables() []string {
+	var names []string
+	for name := range interpreter.Scope.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var lines []string
+	for i := 0; i < len(names); i++ {
+		lines = append(lines, fmt.Sprintf("%s = %s", names[i], FormatValue(interpreter.Scope.Variables[names[i]], true)))
+	}
+	return lines
+}
+
+// REPL reads T# code from in until EOF, `.exit` or `exit`, and runs it
+// in the interpreter, which writes to out meanwhile. The returned error is
+// the `exit` request, if any.
+func (interpreter *Interpreter) REPL(in io.Reader, out io.Writer) (*Error) {
+	defer interpreter.SetOutput(interpreter.Scope.Out)
+	interpreter.SetOutput(out)
+	reader := bufio.NewReader(in)
+	var source string
+	for {
+		if source == "" {
+			fmt.Fprint(out, ReplPrompt)
+		} else {
+			fmt.Fprint(out, ReplContinue)
+		}
+		line, ReadErr := reader.ReadString('\n')
+		if ReadErr != nil && line == "" {
+			fmt.Fprintln(out)
+			return nil
+		}
+		if source == "" && strings.HasPrefix(strings.TrimSpace(line), ".") {
+			command := strings.Fields(line)
+			switch command[0] {
+				case ".exit", ".quit": return nil
+				case ".help": ReplHelp(out)
+				case ".stack": fmt.Fprintln(out, interpreter.FormatStack())
+				case ".vars":
+					lines := interpreter.FormatVariables()
+					for i := 0; i < len(lines); i++ {
+						fmt.Fprintln(out, lines[i])
+					}
+				case ".clear":
+					interpreter.Scope.OpFree()
+					fmt.Fprintln(out, interpreter.FormatStack())
+				case ".load":
+					if len(command) != 2 {
+						fmt.Fprintln(out, "usage: .load <file>")
+						continue
+					}
+					if _, err := interpreter.RunFile(command[1]); err != nil {
+						if err, ok := err.(*Error); ok && err.Type == ExitSignal {
+							return err
+						}
+						ReplError(out, err)
+					}
+					fmt.Fprintln(out, interpreter.FormatStack())
+				default:
+					fmt.Fprintf(out, "unknown command `%s`, see .help\n", command[0])
+			}
+			continue
+		}
+		source += line
+		if Incomplete(source) && ReadErr == nil {
+			continue
+		}
+		if _, err := interpreter.RunString(source, "<stdin>"); err != nil {
+			if err, ok := err.(*Error); ok && err.Type == ExitSignal {
+				return err
+			}
+			ReplError(out, err)
+		}
+		source = ""
+		fmt.Fprintln(out, interpreter.FormatStack())
+	}
+}
+
+// ReplError prints an error like `tsh` does when it runs a file.
+func ReplError(out io.Writer, err error) {
+	if err, ok := err.(*Error); ok {
+		fmt.Fprintln(out, err.Report())
+	} else {
+		fmt.Fprintln(out, err)
+	}
+}
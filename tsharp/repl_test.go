@@ -0,0 +1,44 @@
+package tsharp
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestREPL(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "f.tsp")
+	os.WriteFile(file, []byte("block f do drop end\nf\n"), 0644)
+	tests := []struct {
+		input string
+		want []string
+	}{
+		{"1 2 +\n", []string{"[3]"}},
+		{"block f do\n1\nend\nf f\n", []string{"... ", "[1 1]"}},
+		{"drop\n", []string{"<stdin>:StackUnderflowError:1:1:", "stack: []"}},
+		{".load " + file + "\n", []string{file + ":StackUnderflowError:1:12:", "T# call trace (most recent call last):", "stack: []"}},
+		{".nothing\n", []string{"unknown command `.nothing`"}},
+		{"\"hi\" println \"echo sh\" system\n", []string{"hi\nsh\n[]"}},
+	}
+	for _, test := range tests {
+		var out strings.Builder
+		if err := NewInterpreter().REPL(strings.NewReader(test.input), &out); err != nil {
+			t.Errorf("%q: unexpected error %v", test.input, err)
+		}
+		for _, want := range test.want {
+			if !strings.Contains(out.String(), want) {
+				t.Errorf("%q: the output does not contain %q:\n%s", test.input, want, out.String())
+			}
+		}
+	}
+}
+
+func TestREPLExit(t *testing.T) {
+	var out strings.Builder
+	err := NewInterpreter().REPL(strings.NewReader("3 exit\n1\n"), &out)
+	if err == nil || err.Type != ExitSignal || err.Code != 3 {
+		t.Errorf("got %v, want exit 3", err)
+	}
+}
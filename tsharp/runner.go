@@ -0,0 +1,147 @@
+package tsharp
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+
+// -----------------------------
+// -------- Test runner --------
+// -----------------------------
+
+// TestResult is the outcome of one `test_` block, or of a test file that
+// could not run, when Name is empty. Err is nil when the test passed.
+type TestResult struct {
+	File string
+	Name string
+	Err *Error
+}
+
+// FindTests returns the `*_test.tsp` files of the paths: the files
+// themselves, and the files in the directories and below them.
+func FindTests(paths []string) ([]string, error) {
+	return FindFiles(paths, "_test.tsp")
+}
+
+// FindFiles returns the files of the paths, and the files whose name ends
+// with suffix in the directories and below them, sorted. A file given
+// twice, or given and found in a directory, is returned once.
+func FindFiles(paths []string, suffix string) ([]string, error) {
+	files := []string{}
+	for i := 0; i < len(paths); i++ {
+		info, err := os.Stat(paths[i])
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, filepath.Clean(paths[i]))
+			continue
+		}
+		err = filepath.Walk(paths[i], func(path string, info os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			if !info.IsDir() && strings.HasSuffix(info.Name(), suffix) {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	sort.Strings(files)
+	unique := []string{}
+	for i := 0; i < len(files); i++ {
+		if i == 0 || files[i] != files[i-1] {
+			unique = append(unique, files[i])
+		}
+	}
+	return unique, nil
+}
+
+// TestBlocks returns the `test_` blocks defined at the top of a file, in
+// the order they are defined.
+func TestBlocks(ast AST) []Blockdef {
+	blocks := []Blockdef{}
+	statements, _ := ast.(AsStatements)
+	for i := 0; i < len(statements); i++ {
+		if block, ok := statements[i].(Blockdef); ok && strings.HasPrefix(block.Name, "test_") {
+			blocks = append(blocks, block)
+		}
+	}
+	return blocks
+}
+
+// RunTestFile runs each `test_` block of a file whose name contains
+// filter. Each test gets an interpreter of its own from NewInterpreter,
+// which runs the file first, so the top of the file is the setup of
+// every test, and then calls the block on an empty stack.
+func RunTestFile(path string, filter string, NewInterpreter func() *Interpreter) []TestResult {
+	ast, err := ParseInclude(path, NodePosition{path, 1, 1})
+	if err != nil {
+		return []TestResult{{path, "", err}}
+	}
+	results := []TestResult{}
+	blocks := TestBlocks(ast)
+	for i := 0; i < len(blocks); i++ {
+		if !strings.Contains(blocks[i].Name, filter) {
+			continue
+		}
+		interpreter := NewInterpreter()
+		if _, err := interpreter.RunFile(path); err != nil {
+			return append(results, TestResult{path, "", ToError(err)})
+		}
+		interpreter.Scope.Stack = []AST{}
+		call := AsStatements{AsPush{Var{Name: blocks[i].Name, Position: blocks[i].Position}}}
+		_, err := interpreter.RunAST(call)
+		results = append(results, TestResult{path, blocks[i].Name, ToError(err)})
+	}
+	return results
+}
+
+// ToError is the *Error of an error returned by an interpreter, or nil.
+func ToError(err error) *Error {
+	if err == nil {
+		return nil
+	}
+	if err, ok := err.(*Error); ok {
+		return err
+	}
+	return &Error{message: err.Error(), Type: ErrorVoid}
+}
+
+// RunTests runs the tests of the files, writes a line for each test and
+// a summary to out, and returns how many passed and failed.
+func RunTests(files []string, filter string, NewInterpreter func() *Interpreter, out io.Writer) (int, int) {
+	passed, failed := 0, 0
+	for i := 0; i < len(files); i++ {
+		results := RunTestFile(files[i], filter, NewInterpreter)
+		for j := 0; j < len(results); j++ {
+			result := results[j]
+			if result.Err == nil {
+				passed++
+				fmt.Fprintf(out, "ok   %s %s\n", result.File, result.Name)
+				continue
+			}
+			failed++
+			if result.Name == "" {
+				fmt.Fprintf(out, "FAIL %s\n", result.File)
+			} else {
+				fmt.Fprintf(out, "FAIL %s %s\n", result.File, result.Name)
+			}
+			report := result.Err.Report()
+			if result.Err.Type == ExitSignal {
+				report = fmt.Sprintf("the test called `exit` with status %d.", result.Err.Code)
+			}
+			fmt.Fprintf(out, "    %s\n", strings.ReplaceAll(report, "\n", "\n    "))
+		}
+	}
+	fmt.Fprintf(out, "%d passed, %d failed.\n", passed, failed)
+	return passed, failed
+}
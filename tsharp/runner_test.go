@@ -0,0 +1,34 @@
+package tsharp
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestFindFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"a.tsp", "b_test.tsp", "sub/c.tsp", "notes.txt"} {
+		WriteTestFile(t, filepath.Join(dir, name), "")
+	}
+	a, b, c := filepath.Join(dir, "a.tsp"), filepath.Join(dir, "b_test.tsp"), filepath.Join(dir, "sub", "c.tsp")
+	tests := []struct {
+		paths []string
+		suffix string
+		files []string
+	}{
+		{[]string{dir}, ".tsp", []string{a, b, c}},
+		{[]string{dir}, "_test.tsp", []string{b}},
+		{[]string{c, dir, a, dir + "/./a.tsp"}, ".tsp", []string{a, b, c}},
+		{[]string{filepath.Join(dir, "notes.txt")}, ".tsp", []string{filepath.Join(dir, "notes.txt")}},
+	}
+	for _, test := range tests {
+		files, err := FindFiles(test.paths, test.suffix)
+		if err != nil || !reflect.DeepEqual(files, test.files) {
+			t.Errorf("%v %q: got %v, %v, want %v", test.paths, test.suffix, files, err, test.files)
+		}
+	}
+	if _, err := FindFiles([]string{filepath.Join(dir, "missing")}, ".tsp"); err == nil {
+		t.Errorf("expected an error for a missing path")
+	}
+}
@@ -0,0 +1,2939 @@
+package tsharp
+
+import (
+	"fmt"
+	"math"
+	"math/big"
+	"os"
+	"bufio"
+	"bytes"
+	"io"
+	"reflect"
+	"strconv"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+
+// -----------------------------
+// ----------- Stack -----------
+// -----------------------------
+
+// Scope is the stack and the global variables. Call runs a block for the
+// words that take a quotation; it is OpCall unless the VM runs the scope.
+// Trace holds the block calls the tree walker is running, Includes how
+// many included files it is running, and MaxDepth how many of both there
+// can be before RecursionError. Errors are the error types declared with
+// `exception`, by file and name. Out is where `print`, `println` and
+// `system` write, os.Stdout by default.
+type Scope struct {
+    Stack []AST
+	Variables map[string]AST
+	Call func(block Blockdef, position NodePosition) (*Error)
+	Trace *[]CallSite
+	Includes *int
+	MaxDepth int
+	Modules *Modules
+	Errors map[string]ErrorType
+	Out io.Writer
+}
+
+// Modules are the modules imported by a scope and its sub scopes, by
+// absolute path, so each file runs once. Loading is the chain of imports
+// that are running, to find cycles. Packages are the dependencies of the
+// project around Root, searched after the directory of the importing
+// file, and Path is searched after them and before TSH_PATH.
+type Modules struct {
+	Loaded map[string]AsModule
+	Loading []ModuleFile
+	Packages map[string]Package
+	Path []string
+	Root string
+}
+
+// LoadManifest makes the dependencies of the project around Root
+// importable. It is called by the first import that is not next to the
+// importing file, so a script that needs no package runs whatever the
+// manifest above it holds.
+func (modules *Modules) LoadManifest() (*Error) {
+	if modules.Packages != nil || modules.Root == "" {
+		return nil
+	}
+	path := FindManifest(modules.Root)
+	if path == "" {
+		modules.Packages = map[string]Package{}
+		return nil
+	}
+	packages, err := LoadPackages(path)
+	if err != nil {
+		return err
+	}
+	modules.Packages = packages
+	return nil
+}
+
+type ModuleFile struct {
+	Key string
+	Path string
+	Name string
+}
+
+const DefaultMaxDepth = 10000
+
+// Env holds the variables of one block call. A block or quotation made
+// while a block runs keeps the Env of that call as its Outer, and still
+// sees its variables after the call returns. The tree walker keeps the
+// variables in Vars, the VM in Slots. Tail is the call in tail position
+// the body stopped at, for OpCall to run in its place.
+type Env struct {
+	Vars map[string]AST
+	Slots []AST
+	Outer *Env
+	Tail *CallSite
+	TailBlock Blockdef
+}
+
+func InitScope() *Scope {
+	scope := &Scope{
+		Stack: []AST{},
+		Variables: map[string]AST{},
+		Trace: &[]CallSite{},
+		Includes: new(int),
+		MaxDepth: DefaultMaxDepth,
+		Modules: &Modules{Loaded: map[string]AsModule{}},
+		Errors: map[string]ErrorType{},
+		Out: os.Stdout,
+	}
+	scope.Call = scope.OpCall
+	return scope
+}
+
+func (scope *Scope) SubScope() *Scope {
+	SubScope := &Scope{
+		Stack: []AST{},
+		Variables: scope.Variables,
+		Trace: scope.Trace,
+		Includes: scope.Includes,
+		MaxDepth: scope.MaxDepth,
+		Modules: scope.Modules,
+		Errors: scope.Errors,
+		Out: scope.Out,
+	}
+	SubScope.Call = SubScope.OpCall
+	return SubScope
+}
+
+func (scope *Scope) OpPush(node AST, VariableScope *Env) (*Error) {
+	if _, IsList := node.(NewList); IsList {
+		ListScope := scope.SubScope()
+		if node.(NewList).ListBody != nil {
+			if _, err, _ := ListScope.VisitorVisit(node.(NewList).ListBody, VariableScope); err != nil {
+				return err
+			}
+		}
+		scope.Stack = append(scope.Stack, AsList{ListScope.Stack})
+	} else if _, IsMap := node.(NewMap); IsMap {
+		MapScope := scope.SubScope()
+		if node.(NewMap).MapBody != nil {
+			if _, err, _ := MapScope.VisitorVisit(node.(NewMap).MapBody, VariableScope); err != nil {
+				return err
+			}
+		}
+		value, err := BuildMap(MapScope.Stack, node.(NewMap).Position)
+		if err != nil {
+			return err
+		}
+		scope.Stack = append(scope.Stack, value)
+	} else if _, IsQuote := node.(AsQuote); IsQuote {
+		quote := node.(AsQuote)
+		quote.Block.Env = VariableScope
+		scope.Stack = append(scope.Stack, quote)
+	} else if _, IsVar := node.(Var); IsVar {
+		if value, ok := scope.LookupVar(node.(Var).Name, VariableScope); ok {
+			if node.(Var).Member != "" {
+				var err *Error
+				if value, err = LookupMember(value, node.(Var)); err != nil {
+					return err
+				}
+			}
+			if _, ok := value.(Blockdef); ok {
+				if node.(Var).Tail && VariableScope != nil {
+					VariableScope.SetTail(value.(Blockdef), node.(Var).Position)
+					return nil
+				}
+				return scope.OpCall(value.(Blockdef), node.(Var).Position)
+			}
+			scope.Stack = append(scope.Stack, value)
+			return nil
+		}
+		err := Error{}
+		err.message = fmt.Sprintf("%s:NameError:%d:%d: name `%s` is not defined.", node.(Var).Position.FileName, node.(Var).Position.Line, node.(Var).Position.Column,node.(Var).Name)
+		err.Type = NameError
+		err.Position = node.(Var).Position
+		return &err
+	} else {
+		scope.Stack = append(scope.Stack, node)
+	}
+	return nil
+}
+
+func TypeName(node AST) string {
+	switch node.(type) {
+		case AsStr: return "string"
+		case AsInt, AsBigInt: return "int"
+		case AsFloat: return "float"
+		case AsList: return "list"
+		case AsMap: return "map"
+		case AsQuote: return "quote"
+		case AsModule: return "module"
+		case AsBool: return "bool"
+		case AsType: return "type"
+		case AsError: return "error"
+		case AsFile: return "file"
+	}
+	return ""
+}
+
+func StackEffectString(types []string) string {
+	if len(types) == 0 {
+		return "nothing"
+	}
+	return strings.Join(types, " ")
+}
+
+func (env *Env) SetTail(block Blockdef, position NodePosition) {
+	env.Tail = &CallSite{block.Name, position}
+	env.TailBlock = block
+}
+
+// OutputCheck is a block call whose outputs are checked when it returns.
+type OutputCheck struct {
+	Block Blockdef
+	Position NodePosition
+	Depth int
+}
+
+// AddCheck keeps the output check of a block that made a tail call, to
+// run after the call returns. It is left out when it is the same as the
+// last one, so a block calling itself in tail position runs in constant
+// space.
+func AddCheck(checks []OutputCheck, check OutputCheck) []OutputCheck {
+	if check.Block.Signature == nil {
+		return checks
+	}
+	if len(checks) > 0 {
+		last := checks[len(checks)-1]
+		if last.Block.Signature == check.Block.Signature && last.Depth == check.Depth {
+			return checks
+		}
+	}
+	return append(checks, check)
+}
+
+// EnterCall records a block call, or raises RecursionError when
+// MaxDepth calls are running already.
+func (scope *Scope) EnterCall(block Blockdef, position NodePosition) (*Error) {
+	if len(*scope.Trace) + *scope.Includes >= scope.MaxDepth {
+		return RecursionErr(block, position, scope.MaxDepth)
+	}
+	*scope.Trace = append(*scope.Trace, CallSite{block.Name, position})
+	return nil
+}
+
+func RecursionErr(block Blockdef, position NodePosition, MaxDepth int) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:RecursionError:%d:%d: maximum call depth of %d exceeded calling block `%s`.", position.FileName, position.Line, position.Column, MaxDepth, block.Name)
+	err.Type = RecursionError
+	err.Position = position
+	return &err
+}
+
+// OpCall runs a block. A block with a stack effect signature must find
+// its inputs on the stack and leave exactly its outputs in their place.
+// A call in tail position takes the place of the running one.
+func (scope *Scope) OpCall(block Blockdef, position NodePosition) (*Error) {
+	if err := scope.EnterCall(block, position); err != nil {
+		return err
+	}
+	depth, err := scope.CheckInputs(block, position)
+	if err != nil {
+		*scope.Trace = (*scope.Trace)[:len(*scope.Trace)-1]
+		return err
+	}
+	checks := []OutputCheck{}
+	for {
+		VariableScope := &Env{Vars: map[string]AST{}, Outer: block.Env}
+		_, err, _ = scope.VisitorVisit(block.BlockBody, VariableScope)
+		if err == nil && VariableScope.Tail != nil {
+			next, NextPosition := VariableScope.TailBlock, VariableScope.Tail.Position
+			var NextDepth int
+			if NextDepth, err = scope.CheckInputs(next, NextPosition); err == nil {
+				checks = AddCheck(checks, OutputCheck{block, position, depth})
+				block, position, depth = next, NextPosition, NextDepth
+				(*scope.Trace)[len(*scope.Trace)-1] = *VariableScope.Tail
+				continue
+			}
+		}
+		if err != nil {
+			err.SetTrace(*scope.Trace, scope.Stack)
+		}
+		*scope.Trace = (*scope.Trace)[:len(*scope.Trace)-1]
+		if err != nil {
+			return err
+		}
+		break
+	}
+	if err := scope.CheckOutputs(block, position, depth); err != nil {
+		return err
+	}
+	for i := len(checks)-1; i >= 0; i-- {
+		if err := scope.CheckOutputs(checks[i].Block, checks[i].Position, checks[i].Depth); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CheckInputs checks the inputs of a block before it runs, and returns the
+// stack depth its outputs start at.
+func (scope *Scope) CheckInputs(block Blockdef, position NodePosition) (int, *Error) {
+	depth := len(scope.Stack)
+	if block.Signature == nil {
+		return depth, nil
+	}
+	inputs := block.Signature.Inputs
+	if len(scope.Stack) < len(inputs) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: block `%s` expected ( %s ) in the stack, but the stack has %d element(s).", position.FileName, position.Line, position.Column, block.Name, StackEffectString(inputs), len(scope.Stack))
+		err.Type = StackUnderflowError
+		err.Position = position
+		return depth, &err
+	}
+	for i := 0; i < len(inputs); i++ {
+		value := scope.Stack[len(scope.Stack)-len(inputs)+i]
+		if inputs[i] != "any" && inputs[i] != TypeName(value) {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: block `%s` expected <%s> type as input %d, but got <%s>.", position.FileName, position.Line, position.Column, block.Name, inputs[i], i+1, TypeName(value))
+			err.Type = TypeError
+			err.Position = position
+			return depth, &err
+		}
+	}
+	return depth - len(inputs), nil
+}
+
+func (scope *Scope) CheckOutputs(block Blockdef, position NodePosition, depth int) (*Error) {
+	if block.Signature == nil {
+		return nil
+	}
+	outputs := block.Signature.Outputs
+	if len(scope.Stack) != depth+len(outputs) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: block `%s` should leave ( %s ) in the stack, but left %d element(s).", position.FileName, position.Line, position.Column, block.Name, StackEffectString(outputs), len(scope.Stack)-depth)
+		err.Type = StackUnderflowError
+		err.Position = position
+		if len(scope.Stack) > depth+len(outputs) {
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: block `%s` should leave ( %s ) in the stack, but left %d element(s).", position.FileName, position.Line, position.Column, block.Name, StackEffectString(outputs), len(scope.Stack)-depth)
+			err.Type = TypeError
+		}
+		return &err
+	}
+	for i := 0; i < len(outputs); i++ {
+		value := scope.Stack[depth+i]
+		if outputs[i] != "any" && outputs[i] != TypeName(value) {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: block `%s` should leave <%s> type as output %d, but left <%s>.", position.FileName, position.Line, position.Column, block.Name, outputs[i], i+1, TypeName(value))
+			err.Type = TypeError
+			err.Position = position
+			return &err
+		}
+	}
+	return nil
+}
+
+func (scope *Scope) OpDrop(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `drop` expected one or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return nil
+}
+
+func (scope *Scope) OpSwap(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `swap` expected two or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	first := scope.Stack[len(scope.Stack)-1]
+	second := scope.Stack[len(scope.Stack)-2]
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	scope.OpPush(first, nil)
+	scope.OpPush(second, nil)
+	return nil
+}
+
+func RetTokenAsStr(token uint8) string {
+	return tokens[token]
+}
+
+func IsNumber(node AST) bool {
+	switch node.(type) {
+		case AsInt, AsBigInt, AsFloat: return true
+	}
+	return false
+}
+
+func ToFloat(node AST) float64 {
+	switch node.(type) {
+		case AsInt: return float64(node.(AsInt).IntValue)
+		case AsBigInt:
+			val, _ := new(big.Float).SetInt(node.(AsBigInt).BigValue).Float64()
+			return val
+	}
+	return node.(AsFloat).FloatValue
+}
+
+func (scope *Scope) OpBinop(op uint8, position NodePosition) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected more than 2 <int> type elements in the stack.", position.FileName, position.Line, position.Column, RetTokenAsStr(op))
+		err.Type = StackUnderflowError
+		err.Position = position
+		return &err
+	}
+	first := scope.Stack[len(scope.Stack)-1]
+	second := scope.Stack[len(scope.Stack)-2]
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	_, ok := first.(AsInt);
+	_, ok2 := second.(AsInt);
+
+	_, IsStr := first.(AsStr);
+	_, IsStr2 := second.(AsStr);
+
+	if IsStr && IsStr2 {
+		StrVal := second.(AsStr).StringValue + first.(AsStr).StringValue
+		expr := AsStr {
+			StrVal,
+		}
+		scope.OpPush(expr, nil)
+		return nil
+	}
+
+	_, IsFloat := first.(AsFloat);
+	_, IsFloat2 := second.(AsFloat);
+
+	if (IsFloat || IsFloat2) && IsNumber(first) && IsNumber(second) {
+		var val float64
+		switch op {
+			case TOKEN_PLUS: val = ToFloat(second) + ToFloat(first)
+			case TOKEN_MINUS: val = ToFloat(second) - ToFloat(first)
+			case TOKEN_MUL: val = ToFloat(second) * ToFloat(first)
+			case TOKEN_DIV: val = ToFloat(second) / ToFloat(first)
+			case TOKEN_REM: val = math.Mod(ToFloat(second), ToFloat(first))
+		}
+		scope.OpPush(AsFloat{val}, nil)
+		return nil
+	}
+
+	if !IsInt(first) || !IsInt(second) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected 2 <int> or <float> type or 2 <string> type elements in the stack, but got <%s> and <%s>.", position.FileName, position.Line, position.Column, RetTokenAsStr(op), TypeName(second), TypeName(first))
+		err.Type = TypeError
+		err.Position = position
+		return &err
+	}
+	if (op == TOKEN_DIV || op == TOKEN_REM) && ToBig(first).Sign() == 0 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:ZeroDivisionError:%d:%d: `%s` by zero.", position.FileName, position.Line, position.Column, RetTokenAsStr(op))
+		err.Type = ZeroDivisionError
+		err.Position = position
+		return &err
+	}
+	if ok && ok2 {
+		if val, fits := IntBinop(op, second.(AsInt).IntValue, first.(AsInt).IntValue); fits {
+			scope.OpPush(AsInt{val}, nil)
+			return nil
+		}
+	}
+	a := ToBig(second)
+	b := ToBig(first)
+	val := new(big.Int)
+	switch op {
+		case TOKEN_PLUS: val.Add(a, b)
+		case TOKEN_MINUS: val.Sub(a, b)
+		case TOKEN_MUL: val.Mul(a, b)
+		case TOKEN_DIV: val.Quo(a, b)
+		case TOKEN_REM: val.Rem(a, b)
+	}
+	scope.OpPush(NormalizeInt(val), nil)
+	return nil
+}
+
+const (
+	MaxInt = 1<<(strconv.IntSize-1) - 1
+	MinInt = -1 << (strconv.IntSize-1)
+)
+
+// IntBinop does int arithmetic, and reports false when the result
+// does not fit in an int.
+func IntBinop(op uint8, a int, b int) (int, bool) {
+	switch op {
+		case TOKEN_PLUS:
+			val := a + b
+			return val, (val > a) == (b > 0)
+		case TOKEN_MINUS:
+			val := a - b
+			return val, (val < a) == (b > 0)
+		case TOKEN_MUL:
+			if a == 0 || b == 0 {
+				return 0, true
+			}
+			if (a == -1 && b == MinInt) || (b == -1 && a == MinInt) {
+				return 0, false
+			}
+			val := a * b
+			return val, val/b == a
+		case TOKEN_DIV:
+			if a == MinInt && b == -1 {
+				return 0, false
+			}
+			return a / b, true
+	}
+	return a % b, true
+}
+
+func IsBigInt(node AST) bool {
+	_, ok := node.(AsBigInt)
+	return ok
+}
+
+// IndexValue is an <int> index as an int. An AsBigInt is -1, which is
+// out of range like it.
+func IndexValue(node AST) int {
+	if IsBigInt(node) {
+		return -1
+	}
+	return node.(AsInt).IntValue
+}
+
+func IsInt(node AST) bool {
+	switch node.(type) {
+		case AsInt, AsBigInt: return true
+	}
+	return false
+}
+
+func ToBig(node AST) *big.Int {
+	if _, ok := node.(AsInt); ok {
+		return big.NewInt(int64(node.(AsInt).IntValue))
+	}
+	return node.(AsBigInt).BigValue
+}
+
+// NormalizeInt turns a result back into an AsInt when it fits, so an
+// <int> value is an AsBigInt only when it has to be.
+func NormalizeInt(value *big.Int) AST {
+	if value.IsInt64() && value.Int64() >= MinInt && value.Int64() <= MaxInt {
+		return AsInt{int(value.Int64())}
+	}
+	return AsBigInt{value}
+}
+
+
+func (scope *Scope) OpCompare(op uint8, position NodePosition) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected more than 2 elements in the stack.", position.FileName, position.Line, position.Column, RetTokenAsStr(op))
+		err.Type = StackUnderflowError
+		err.Position = position
+		return &err
+	}
+	first := scope.Stack[len(scope.Stack)-1]
+	second := scope.Stack[len(scope.Stack)-2]
+	var val bool
+	_, IsFloat := first.(AsFloat)
+	_, IsFloat2 := second.(AsFloat)
+	FloatOperands := IsNumber(first) && IsNumber(second) && (IsFloat || IsFloat2)
+	if FloatOperands && op != TOKEN_OR && op != TOKEN_AND {
+		switch op {
+			case TOKEN_IS_EQUALS: val = ToFloat(second) == ToFloat(first)
+			case TOKEN_NOT_EQUALS: val = ToFloat(second) != ToFloat(first)
+			case TOKEN_LESS_THAN: val = ToFloat(second) < ToFloat(first)
+			case TOKEN_LESS_EQUALS: val = ToFloat(second) <= ToFloat(first)
+			case TOKEN_GREATER_THAN: val = ToFloat(second) > ToFloat(first)
+			case TOKEN_GREATER_EQUALS: val = ToFloat(second) >= ToFloat(first)
+		}
+	} else if op == TOKEN_IS_EQUALS {
+		val = ValuesEqual(second, first)
+	} else if op == TOKEN_NOT_EQUALS {
+		val = !ValuesEqual(second, first)
+	} else if op == TOKEN_OR || op == TOKEN_AND {
+		_, ok := first.(AsBool);
+		_, ok2 := second.(AsBool);
+		if !ok || !ok2 {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected 2 <bool> type elements in the stack.", position.FileName, position.Line, position.Column, RetTokenAsStr(op))
+			err.Type = TypeError
+			err.Position = position
+			return &err
+		}
+		switch op {
+			case TOKEN_OR: val = second.(AsBool).BoolValue || first.(AsBool).BoolValue
+			case TOKEN_AND: val = second.(AsBool).BoolValue && first.(AsBool).BoolValue
+		}
+	} else if _, IsBig := first.(AsBigInt); IsBig && IsInt(second) || IsInt(first) && IsBigInt(second) {
+		cmp := ToBig(second).Cmp(ToBig(first))
+		switch op {
+			case TOKEN_LESS_THAN: val = cmp < 0
+			case TOKEN_LESS_EQUALS: val = cmp <= 0
+			case TOKEN_GREATER_THAN: val = cmp > 0
+			case TOKEN_GREATER_EQUALS: val = cmp >= 0
+		}
+	} else {
+		_, ok := first.(AsInt);
+		_, ok2 := second.(AsInt);
+		if !ok || !ok2 {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected 2 <int> or <float> type elements in the stack.", position.FileName, position.Line, position.Column, RetTokenAsStr(op))
+			err.Type = TypeError
+			err.Position = position
+			return &err
+		}
+		switch op {
+			case TOKEN_LESS_THAN: val = second.(AsInt).IntValue < first.(AsInt).IntValue
+			case TOKEN_LESS_EQUALS: val = second.(AsInt).IntValue <= first.(AsInt).IntValue
+			case TOKEN_GREATER_THAN: val = second.(AsInt).IntValue > first.(AsInt).IntValue
+			case TOKEN_GREATER_EQUALS: val = second.(AsInt).IntValue >= first.(AsInt).IntValue
+		}
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	expr := AsBool {
+		val,
+	}
+	scope.OpPush(expr, nil)
+	return nil
+}
+
+// ValuesEqual is the equality of `==`: numbers are equal by value, and
+// other values when they have the same type and contents, which are
+// compared with ValuesEqual in turn.
+func ValuesEqual(second AST, first AST) bool {
+	_, IsFloat := first.(AsFloat)
+	_, IsFloat2 := second.(AsFloat)
+	if IsNumber(first) && IsNumber(second) && (IsFloat || IsFloat2) {
+		return ToFloat(second) == ToFloat(first)
+	}
+	if reflect.TypeOf(first) != reflect.TypeOf(second) {
+		return false
+	}
+	switch first.(type) {
+		case AsStr: return first.(AsStr).StringValue == second.(AsStr).StringValue
+		case AsInt: return second.(AsInt).IntValue == first.(AsInt).IntValue
+		case AsBigInt: return second.(AsBigInt).BigValue.Cmp(first.(AsBigInt).BigValue) == 0
+		case AsBool: return second.(AsBool).BoolValue == first.(AsBool).BoolValue
+		case AsType: return second.(AsType).TypeValue == first.(AsType).TypeValue
+		case AsError: return second.(AsError).err == first.(AsError).err
+		case AsList:
+			a, b := second.(AsList).ListArgs, first.(AsList).ListArgs
+			if len(a) != len(b) {
+				return false
+			}
+			for i := 0; i < len(a); i++ {
+				if !ValuesEqual(a[i], b[i]) {
+					return false
+				}
+			}
+			return true
+		case AsMap: return MapEqual(second.(AsMap), first.(AsMap))
+		case Blockdef: return reflect.DeepEqual(second.(Blockdef), first.(Blockdef))
+	}
+	return false
+}
+
+func FormatValue(node AST, quote bool) string {
+	switch node.(type) {
+		case AsStr:
+			if quote {
+				return strconv.Quote(node.(AsStr).StringValue)
+			}
+			return node.(AsStr).StringValue
+		case AsInt: return strconv.Itoa(node.(AsInt).IntValue)
+		case AsBigInt: return node.(AsBigInt).BigValue.String()
+		case AsFloat: return FormatFloat(node.(AsFloat).FloatValue)
+		case AsBool: return strconv.FormatBool(node.(AsBool).BoolValue)
+		case AsType: return fmt.Sprintf("<%s>", node.(AsType).TypeValue)
+		case AsFile: return fmt.Sprintf("<file %s>", node.(AsFile).FileName)
+		case AsError:
+			if node.(AsError).message != "" {
+				return fmt.Sprintf("<error '%s': %s>", node.(AsError).Name(), node.(AsError).message)
+			}
+			return fmt.Sprintf("<error '%s'>", node.(AsError).Name())
+		case Blockdef: return fmt.Sprintf("<block %s>", node.(Blockdef).Name)
+		case AsQuote: return "<quote>"
+		case AsModule: return fmt.Sprintf("<module %s>", node.(AsModule).Name)
+		case AsMap:
+			var buffer bytes.Buffer
+			buffer.WriteString("[")
+			for i := 0; i < len(node.(AsMap).Keys); i++ {
+				buffer.WriteString(FormatValue(node.(AsMap).Keys[i], quote))
+				buffer.WriteString(": ")
+				buffer.WriteString(FormatValue(node.(AsMap).Values[i], quote))
+				if i < len(node.(AsMap).Keys)-1 {
+					buffer.WriteString(", ")
+				}
+			}
+			buffer.WriteString("]")
+			return buffer.String()
+		case AsList:
+			var buffer bytes.Buffer
+			buffer.WriteString("{")
+			for i := 0; i < len(node.(AsList).ListArgs); i++ {
+				buffer.WriteString(FormatValue(node.(AsList).ListArgs[i], quote))
+				if i < len(node.(AsList).ListArgs)-1 {
+					buffer.WriteString(", ")
+				}
+			}
+			buffer.WriteString("}")
+			return buffer.String()
+	}
+	return fmt.Sprintf("%v", node)
+}
+
+// FormatFloat formats a float so that it does not read as an int.
+func FormatFloat(value float64) string {
+	text := strconv.FormatFloat(value, 'g', -1, 64)
+	if !strings.ContainsAny(text, ".eIN") {
+		text += ".0"
+	}
+	return text
+}
+
+func PrintAsList(node AST) {
+	fmt.Print(FormatValue(node, false))
+}
+
+func (scope *Scope) OpPrintln(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `println` the stack is empty.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	expr := scope.Stack[len(scope.Stack)-1]
+	fmt.Fprintln(scope.Out, FormatValue(expr, false))
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return nil
+}
+
+func (scope *Scope) OpPrint(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `print` the stack is empty.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	expr := scope.Stack[len(scope.Stack)-1]
+	fmt.Fprint(scope.Out, FormatValue(expr, false))
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return nil
+}
+
+// OpCondition pops the <bool> an `if` or a `for` tests.
+func (scope *Scope) OpCondition(what string, position NodePosition) (bool, *Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: %s expected one or more <bool> type element in the stack.", position.FileName, position.Line, position.Column, what)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return false, &err
+	}
+	expr := scope.Stack[len(scope.Stack)-1]
+	if _, ok := expr.(AsBool); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: %s expected <bool> type element in the stack, but got <%s>.", position.FileName, position.Line, position.Column, what, TypeName(expr))
+		err.Type = TypeError
+		err.Position = position
+		return false, &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return expr.(AsBool).BoolValue, nil
+}
+
+func (scope *Scope) OpIf(node AST, VariableScope *Env) (bool, *Error) {
+	var BreakValue bool = false
+	_, err, _ := scope.VisitorVisit(node.(If).IfOp, VariableScope)
+	if err != nil {
+		return BreakValue, err
+	}
+	cond, err := scope.OpCondition("if statement", node.(If).Position)
+	if err != nil {
+		return BreakValue, err
+	}
+	if cond {
+		BreakValue, err, _ = scope.VisitorVisit(node.(If).IfBody, VariableScope)
+		if err != nil {
+			return BreakValue, err
+		}
+		return BreakValue, nil
+	}
+	for i := 0; i < len(node.(If).ElifOps); i++ {
+		if _, err, _ := scope.VisitorVisit(node.(If).ElifOps[i], VariableScope); err != nil {
+			return BreakValue, err
+		}
+		cond, err := scope.OpCondition("if statement", node.(If).ElifPositions[i])
+		if err != nil {
+			return BreakValue, err
+		}
+		if cond {
+			BreakValue, err, _ = scope.VisitorVisit(node.(If).ElifBodys[i], VariableScope)
+			return BreakValue, err
+		}
+	}
+	if node.(If).ElseBody != nil {
+		BreakValue, err, _ = scope.VisitorVisit(node.(If).ElseBody, VariableScope)
+	}
+	return BreakValue, err
+}
+
+func (scope *Scope) OpFor(node AST, VariableScope *Env) (*Error) {
+	var BreakValue bool
+	LOOP:
+		_, err, _ := scope.VisitorVisit(node.(For).ForOp, VariableScope)
+		if err != nil {
+			return err
+		}
+		cond, err := scope.OpCondition("for loop", node.(For).Position)
+		if err != nil {
+			return err
+		}
+		if !cond {
+			return nil
+		}
+		BreakValue, err, _ = scope.VisitorVisit(node.(For).ForBody, VariableScope)
+		if err != nil {
+			return err
+		}
+		if BreakValue {
+			return nil
+		}
+	goto LOOP
+}
+
+func (scope *Scope) LookupVar(name string, VariableScope *Env) (AST, bool) {
+	for env := VariableScope; env != nil; env = env.Outer {
+		if value, ok := env.Vars[name]; ok {
+			return value, true
+		}
+	}
+	value, ok := scope.Variables[name]
+	return value, ok
+}
+
+// OpExceptError resolves the error named in an `except` clause.
+// `except error` catches everything and resolves to ErrorVoid.
+func (scope *Scope) OpExceptError(node AST, VariableScope *Env) (ErrorType, *Error) {
+	if _, ok := node.(AsError); ok {
+		return node.(AsError).err, nil
+	}
+	if _, ok := node.(AsType); ok {
+		return ErrorVoid, nil
+	}
+	value, ok := scope.LookupVar(node.(Var).Name, VariableScope)
+	if !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:NameError:%d:%d: name `%s` is not defined.", node.(Var).Position.FileName, node.(Var).Position.Line, node.(Var).Position.Column, node.(Var).Name)
+		err.Type = NameError
+		err.Position = node.(Var).Position
+		return ErrorVoid, &err
+	}
+	if node.(Var).Member != "" {
+		var err *Error
+		if value, err = LookupMember(value, node.(Var)); err != nil {
+			return ErrorVoid, err
+		}
+	}
+	if _, ok := value.(AsError); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `except` expected <error> type, `%s` is not an error.", node.(Var).Position.FileName, node.(Var).Position.Line, node.(Var).Position.Column, node.(Var).Name)
+		err.Type = TypeError
+		err.Position = node.(Var).Position
+		return ErrorVoid, &err
+	}
+	return value.(AsError).err, nil
+}
+
+// OpTry runs a try statement. A `break` in it leaves the loop around it
+// after the finally body, unless there is an error to raise.
+func (scope *Scope) OpTry(node AST, VariableScope *Env) (bool, *Error) {
+	BreakValue, err, _ := scope.VisitorVisit(node.(Try).TryBody, VariableScope)
+	if err != nil && err.Type != ExitSignal {
+		for i := 0; i < len(node.(Try).ExceptErrors); i++ {
+			ErrorValue, ExceptErr := scope.OpExceptError(node.(Try).ExceptErrors[i], VariableScope)
+			if ExceptErr != nil {
+				err = ExceptErr
+				break
+			}
+			if ErrorValue == err.Type || ErrorValue == ErrorVoid {
+				scope.OpPush(AsError{err.Type, err.message, err.Position, err.Name}, nil)
+				if node.(Try).ExceptNames[i] != "" {
+					scope.OpVardef(node.(Try).ExceptNames[i], err.Position, VariableScope)
+				}
+				BreakValue, err, _ = scope.VisitorVisit(node.(Try).ExceptBodys[i], VariableScope)
+				break
+			}
+		}
+	}
+	if node.(Try).FinallyBody != nil {
+		FinallyBreak, FinallyErr, _ := scope.VisitorVisit(node.(Try).FinallyBody, VariableScope)
+		if FinallyErr != nil {
+			return false, FinallyErr
+		}
+		BreakValue = BreakValue || FinallyBreak
+	}
+	return BreakValue, err
+}
+
+func (scope *Scope) OpRethrow(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `rethrow` expected one or more <error> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	ErrorValue := scope.Stack[len(scope.Stack)-1]
+	if _, ok := ErrorValue.(AsError); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `rethrow` expected <error> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	err := Error{}
+	err.message = ErrorValue.(AsError).message
+	err.Type = ErrorValue.(AsError).err
+	err.Name = ErrorValue.(AsError).name
+	err.Position = ErrorValue.(AsError).Position
+	if err.message == "" {
+		err.message = fmt.Sprintf("%s:%s:%d:%d: ", node.(AsId).Position.FileName, err.TypeName(), node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Position = node.(AsId).Position
+	}
+	return &err
+}
+
+func (scope *Scope) OpErrmsg(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `errmsg` expected one or more <error> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	ErrorValue := scope.Stack[len(scope.Stack)-1]
+	if _, ok := ErrorValue.(AsError); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `errmsg` expected <error> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	Caught := Error{
+		message: ErrorValue.(AsError).message,
+		Type: ErrorValue.(AsError).err,
+		Name: ErrorValue.(AsError).name,
+		Position: ErrorValue.(AsError).Position,
+	}
+	scope.OpPush(AsStr{Caught.Text()}, nil)
+	return nil
+}
+
+func (scope *Scope) OpRaise(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `raise` expected <string> and <error> type elements in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	ErrorValue := scope.Stack[len(scope.Stack)-1]
+	Message := scope.Stack[len(scope.Stack)-2]
+	if _, ok := ErrorValue.(AsError); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `raise` expected <error> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	if _, ok := Message.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `raise` expected <string> type message in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	err := Error{}
+	err.message = fmt.Sprintf("%s:%s:%d:%d: %s", node.(AsId).Position.FileName, ErrorValue.(AsError).Name(), node.(AsId).Position.Line, node.(AsId).Position.Column, Message.(AsStr).StringValue)
+	err.Type = ErrorValue.(AsError).err
+	err.Name = ErrorValue.(AsError).name
+	err.Position = node.(AsId).Position
+	return &err
+}
+
+func (scope *Scope) OpInc(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `inc` expected one or more <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	first := scope.Stack[len(scope.Stack)-1]
+	if !IsInt(first) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `inc` expected <int> type element in the stack, but got <%s>.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, TypeName(first))
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	if _, ok := first.(AsInt); ok {
+		if val, fits := IntBinop(TOKEN_PLUS, first.(AsInt).IntValue, 1); fits {
+			scope.OpPush(AsInt{val}, nil)
+			return nil
+		}
+	}
+	val := new(big.Int).Add(ToBig(first), big.NewInt(1))
+	scope.OpPush(NormalizeInt(val), nil)
+	return nil
+}
+
+func (scope *Scope) OpDec(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `dec` expected one or more <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	first := scope.Stack[len(scope.Stack)-1]
+	if !IsInt(first) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `dec` expected <int> type element in the stack, but got <%s>.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, TypeName(first))
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	if _, ok := first.(AsInt); ok {
+		if val, fits := IntBinop(TOKEN_MINUS, first.(AsInt).IntValue, 1); fits {
+			scope.OpPush(AsInt{val}, nil)
+			return nil
+		}
+	}
+	val := new(big.Int).Sub(ToBig(first), big.NewInt(1))
+	scope.OpPush(NormalizeInt(val), nil)
+	return nil
+}
+
+func (scope *Scope) OpDup(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `dup` expected one or more <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	first := scope.Stack[len(scope.Stack)-1]
+	scope.OpPush(first, nil)
+	return nil
+}
+
+func (scope *Scope) OpAppend(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `append` expected two or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	if _, ok := scope.Stack[len(scope.Stack)-2].(AsList); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `append` expected <list> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	a := append(scope.Stack[len(scope.Stack)-2].(AsList).ListArgs, scope.Stack[len(scope.Stack)-1])
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	var NewList AST = AsList {
+		a,
+	}
+	scope.OpPush(NewList, nil)
+	return nil
+}
+
+func (scope *Scope) OpRead(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `read` expected two or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedList := scope.Stack[len(scope.Stack)-2]
+	visitedIndex := scope.Stack[len(scope.Stack)-1]
+	if !IsInt(visitedIndex) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `read` index expected <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	_, ok := visitedList.(AsStr);
+	_, ok2 := visitedList.(AsList);
+	if !ok && !ok2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `read` expected <list> or <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	index := IndexValue(visitedIndex)
+	if _, ok := visitedList.(AsList); ok {
+		if len(visitedList.(AsList).ListArgs) <= index || index < 0 {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:IndexError:%d:%d: `read` type <list> element index out of range.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+			err.Type = IndexError
+			err.Position = node.(AsId).Position
+			return &err
+		}
+		scope.OpPush(visitedList.(AsList).ListArgs[index], nil)
+	} else {
+		if len([]rune(visitedList.(AsStr).StringValue)) <= index || index < 0 {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:IndexError:%d:%d: `read` type <string> element index out of range.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+			err.Type = IndexError
+			err.Position = node.(AsId).Position
+			return &err
+		}
+		StringValue := string([]rune(visitedList.(AsStr).StringValue)[index])
+		var StrExpr AST = AsStr {
+			StringValue,
+		}
+		scope.OpPush(StrExpr, nil)
+	}
+	return nil
+}
+
+func (scope *Scope) OpReplace(node AST) (*Error) {
+	if len(scope.Stack) < 3 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `replace` expected three or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedList := scope.Stack[len(scope.Stack)-3]
+	visitedValue := scope.Stack[len(scope.Stack)-2]
+	visitedIndex := scope.Stack[len(scope.Stack)-1]
+	if !IsInt(visitedIndex) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `replace` index expected <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	if _, ok := visitedList.(AsList); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `replace` expected <list> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	index := IndexValue(visitedIndex)
+	if len(visitedList.(AsList).ListArgs) <= index || index < 0 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:IndexError:%d:%d: `replace` type <list> element index out of range.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = IndexError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedList.(AsList).ListArgs[index] = visitedValue
+	scope.Stack = scope.Stack[:len(scope.Stack)-3]
+	scope.OpPush(visitedList, nil)
+	return nil
+}
+
+func (scope *Scope) OpRemove(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `remove` expected two or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedList := scope.Stack[len(scope.Stack)-2]
+	visitedIndex := scope.Stack[len(scope.Stack)-1]
+	if !IsInt(visitedIndex) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `remove` index expected <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	if _, ok := visitedList.(AsList); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `remove` expected <list> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	index := IndexValue(visitedIndex)
+	if len(visitedList.(AsList).ListArgs) <= index || index < 0 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:IndexError:%d:%d: `remove` type <list> element index out of range.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = IndexError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	NewList := append(visitedList.(AsList).ListArgs[:index], visitedList.(AsList).ListArgs[index+1:]...)
+    var ListExpr AST = AsList {
+		NewList,
+	}
+	visitedList = nil
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	scope.OpPush(ListExpr, nil)
+	return nil
+}
+
+func (scope *Scope) OpIn(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `in` expected two or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedVal := scope.Stack[len(scope.Stack)-2]
+	visitedList := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+	if _, ok := visitedList.(AsList); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `in` expected <list> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	for i := 0; i < len(visitedList.(AsList).ListArgs); i++ {
+		var val AST
+		switch visitedVal.(type) {
+			case AsStr: val = visitedVal.(AsStr)
+			case AsInt: val = visitedVal.(AsInt)
+			case AsBigInt: val = visitedVal.(AsBigInt)
+			case AsFloat: val = visitedVal.(AsFloat)
+			case AsList: val = visitedVal.(AsList)
+		}
+		arg := visitedList.(AsList).ListArgs[i]
+		if IsBigInt(arg) && IsBigInt(val) && arg.(AsBigInt).BigValue.Cmp(val.(AsBigInt).BigValue) == 0 || arg == val {
+			expr := AsBool {
+				true,
+			}
+			scope.OpPush(expr, nil)
+			return nil
+		}
+	}
+	expr := AsBool {
+		false,
+	}
+	scope.OpPush(expr, nil)
+	return nil
+}
+
+func (scope *Scope) OpLen(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `len` expected one or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedExpr := scope.Stack[len(scope.Stack)-1]
+	_, ok := visitedExpr.(AsList);
+	_, ok2 := visitedExpr.(AsStr);
+	_, ok3 := visitedExpr.(AsMap);
+	if !ok && !ok2 && !ok3 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `len` expected <list>, <string> or <map> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	IntExpr := AsInt {}
+	if ok {
+		IntExpr.IntValue = len(visitedExpr.(AsList).ListArgs)
+	} else if ok3 {
+		IntExpr.IntValue = len(visitedExpr.(AsMap).Keys)
+	} else {
+		IntExpr.IntValue = len([]rune(visitedExpr.(AsStr).StringValue))
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	scope.OpPush(IntExpr, nil)
+	return nil
+}
+
+func (scope *Scope) OpTypeOf(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `typeof` expected one or more element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedVal := scope.Stack[len(scope.Stack)-1]
+	expr := AsType {
+		TypeName(visitedVal),
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	scope.OpPush(expr, nil)
+	return nil
+}
+
+func (scope *Scope) OpRot(node AST) (*Error) {
+	if len(scope.Stack) < 3 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `rot` expected more than three elements in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	visitedExpr := scope.Stack[len(scope.Stack)-1]
+	visitedExprSecond := scope.Stack[len(scope.Stack)-2]
+	visitedExprThird := scope.Stack[len(scope.Stack)-3]
+	scope.Stack = scope.Stack[:len(scope.Stack)-3]
+	scope.OpPush(visitedExprSecond, nil)
+	scope.OpPush(visitedExpr, nil)
+	scope.OpPush(visitedExprThird, nil)
+	return nil
+}
+
+func (scope *Scope) OpOver(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `over` expected more than two elements in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.OpPush(scope.Stack[len(scope.Stack)-2], nil)
+	return nil
+}
+
+func (scope *Scope) OpVardef(name string, position NodePosition, VariableScope *Env) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: variable `%s` definiton expected one or more element in the stack.", position.FileName, position.Line, position.Column, name)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return &err
+	}
+	VarValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	if VariableScope == nil {
+		scope.Variables[name] = VarValue
+	} else {
+		VariableScope.Vars[name] = VarValue
+	}
+	return nil
+}
+
+// OpAssign is `=> name`: it assigns the nearest variable with that name,
+// in the block, in the blocks around it or in the globals.
+func (scope *Scope) OpAssign(name string, position NodePosition, VariableScope *Env) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: variable `%s` assignment expected one or more element in the stack.", position.FileName, position.Line, position.Column, name)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return &err
+	}
+	VarValue := scope.Stack[len(scope.Stack)-1]
+	for env := VariableScope; env != nil; env = env.Outer {
+		if _, ok := env.Vars[name]; ok {
+			env.Vars[name] = VarValue
+			scope.Stack = scope.Stack[:len(scope.Stack)-1]
+			return nil
+		}
+	}
+	if _, ok := scope.Variables[name]; !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:NameError:%d:%d: name `%s` is not defined, `=>` only assigns to an existing variable.", position.FileName, position.Line, position.Column, name)
+		err.Type = NameError
+		err.Position = position
+		return &err
+	}
+	scope.Variables[name] = VarValue
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return nil
+}
+
+// OpBlockdef defines a block. A block defined inside another block is
+// local to it, and keeps its variables.
+func (scope *Scope) OpBlockdef(node AST, VariableScope *Env) (*Error) {
+	if VariableScope == nil {
+		scope.Variables[node.(Blockdef).Name] = node
+		return nil
+	}
+	block := node.(Blockdef)
+	block.Env = VariableScope
+	VariableScope.Vars[block.Name] = block
+	return nil
+}
+
+// OpErrordef declares an error type and defines its name like `->`, so
+// the exceptions of a module are members of it.
+func (scope *Scope) OpErrordef(node AST, VariableScope *Env) (*Error) {
+	scope.OpPush(scope.DeclareError(node.(Errordef)), nil)
+	return scope.OpVardef(node.(Errordef).Name, node.(Errordef).Position, VariableScope)
+}
+
+// DeclareError returns the error type of an `exception`. Its name is
+// qualified with the module that runs it, and declaring it again in the
+// same file gives the same type.
+func (scope *Scope) DeclareError(node Errordef) AsError {
+	name := node.Name
+	if loading := scope.Modules.Loading; len(loading) > 0 && loading[len(loading)-1].Name != "" {
+		name = loading[len(loading)-1].Name + "." + name
+	}
+	key := node.Position.FileName + ":" + name
+	Type, ok := scope.Errors[key]
+	if !ok {
+		Type = ErrorType(BuiltinErrorCount + len(scope.Errors))
+		scope.Errors[key] = Type
+	}
+	return AsError{err: Type, name: name}
+}
+
+// OpInclude runs an included file. It counts toward the call depth like
+// a block call, so a file that includes itself raises RecursionError.
+func (scope *Scope) OpInclude(FileName string, position NodePosition) (*Error) {
+	if len(*scope.Trace) + *scope.Includes >= scope.MaxDepth {
+		return IncludeDepthErr(FileName, position, scope.MaxDepth)
+	}
+	ast, err := ParseInclude(FileName, position)
+	if err != nil {
+		return err
+	}
+	*scope.Includes++
+	defer func() { *scope.Includes-- }()
+	_, err, _ = scope.VisitorVisit(ast, nil)
+	return err
+}
+
+func IncludeDepthErr(FileName string, position NodePosition, MaxDepth int) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:RecursionError:%d:%d: maximum call depth of %d exceeded including `%s`.", position.FileName, position.Line, position.Column, MaxDepth, FileName)
+	err.Type = RecursionError
+	err.Position = position
+	return &err
+}
+
+func ParseInclude(FileName string, position NodePosition) (AST, *Error) {
+	if _, err := os.Stat(FileName); os.IsNotExist(err) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:IncludeError:%d:%d: invalid file name `%s`.", position.FileName, position.Line, position.Column, FileName)
+		err.Type = IncludeError
+		err.Position = position
+		return nil, &err
+	}
+	file, OpenErr := os.Open(FileName)
+	if OpenErr != nil {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:IncludeError:%d:%d: cannot open file `%s`.", position.FileName, position.Line, position.Column, FileName)
+		err.Type = IncludeError
+		err.Position = position
+		return nil, &err
+	}
+	defer file.Close()
+	lexer := LexerInit(file, FileName)
+	parser, err := ParserInit(lexer)
+	if err != nil {
+		return nil, err
+	}
+	return ParserParse(parser)
+}
+
+func (scope *Scope) OpAssert(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `assert` expected one or more <bool> type element in the stack.", node.(Assert).Position.FileName, node.(Assert).Position.Line, node.(Assert).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(Assert).Position
+		return &err
+	}
+	BoolValue := scope.Stack[len(scope.Stack)-1]
+	if _, ok := BoolValue.(AsBool); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `assert` expected <bool> type element in the stack.", node.(Assert).Position.FileName, node.(Assert).Position.Line, node.(Assert).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(Assert).Position
+		return &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	if !BoolValue.(AsBool).BoolValue {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:AssertionError:%d:%d: %s", node.(Assert).Position.FileName, node.(Assert).Position.Line, node.(Assert).Position.Column, node.(Assert).Message)
+		err.Type = AssertionError
+		err.Position = node.(Assert).Position
+		return &err
+	}
+	return nil
+}
+
+// AssertFailed is the AssertionError of the assertion words.
+func AssertFailed(position NodePosition, message string) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:AssertionError:%d:%d: %s", position.FileName, position.Line, position.Column, message)
+	err.Type = AssertionError
+	err.Position = position
+	return &err
+}
+
+func (scope *Scope) OpAssertEqual(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "actual expected", "any", "any")
+	if err != nil {
+		return err
+	}
+	if !ValuesEqual(args[0], args[1]) {
+		return AssertFailed(node.(AsId).Position, fmt.Sprintf("expected %s, got %s.", FormatValue(args[1], true), FormatValue(args[0], true)))
+	}
+	return nil
+}
+
+func (scope *Scope) OpAssertNotEqual(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "actual other", "any", "any")
+	if err != nil {
+		return err
+	}
+	if ValuesEqual(args[0], args[1]) {
+		return AssertFailed(node.(AsId).Position, fmt.Sprintf("expected a value other than %s.", FormatValue(args[1], true)))
+	}
+	return nil
+}
+
+// OpAssertDepth checks the number of elements left in the stack below
+// the expected depth.
+func (scope *Scope) OpAssertDepth(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<int>", "int")
+	if err != nil {
+		return err
+	}
+	if depth := args[0].(AsInt).IntValue; depth != len(scope.Stack) {
+		return AssertFailed(node.(AsId).Position, fmt.Sprintf("expected a stack depth of %d, got %d: %s.", depth, len(scope.Stack), FormatStack(scope.Stack)))
+	}
+	return nil
+}
+
+// OpAssertRaises runs the quotation and checks that it raised an error
+// of the kind, or any error for the `error` type. The stack is put back
+// as it was before the quotation ran.
+func (scope *Scope) OpAssertRaises(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<quote> <error>", "quote", "error")
+	if err != nil {
+		return err
+	}
+	position := node.(AsId).Position
+	stack := append([]AST{}, scope.Stack...)
+	raised := scope.Call(args[0].(AsQuote).Block, position)
+	scope.Stack = stack
+	if raised != nil && raised.Type == ExitSignal {
+		return raised
+	}
+	expected := "an error"
+	if kind, ok := args[1].(AsError); ok {
+		expected = kind.Name()
+		if raised != nil && raised.Type != kind.err {
+			return AssertFailed(position, fmt.Sprintf("expected %s, got %s: %s", expected, raised.TypeName(), raised.Text()))
+		}
+	}
+	if raised == nil {
+		return AssertFailed(position, fmt.Sprintf("expected %s, but nothing was raised.", expected))
+	}
+	return nil
+}
+
+func (scope *Scope) OpInput() {
+	inputReader := bufio.NewReader(os.Stdin)
+	input, _ := inputReader.ReadString('\n')
+	input = strings.TrimSuffix(input, "\n")
+	
+	StrExpr := AsStr {
+		input,
+	}
+	scope.OpPush(StrExpr, nil)
+}
+
+func (scope *Scope) OpExit(node AST) (*Error) {
+	position := node.(AsId).Position
+	err := Error{}
+	err.message = "exit"
+	err.Type = ExitSignal
+	err.Position = position
+	if len(scope.Stack) == 0 {
+		return &err
+	}
+	status := scope.Stack[len(scope.Stack)-1]
+	if !IsInt(status) {
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `exit` expected <int> type element in the stack, but got <%s>.", position.FileName, position.Line, position.Column, TypeName(status))
+		err.Type = TypeError
+		return &err
+	}
+	if code, ok := status.(AsInt); !ok || code.IntValue < 0 || code.IntValue > 255 {
+		err.message = fmt.Sprintf("%s:ValueError:%d:%d: `exit` expected a status from 0 to 255, but got %s.", position.FileName, position.Line, position.Column, FormatValue(status, true))
+		err.Type = ValueError
+		return &err
+	}
+	err.Code = status.(AsInt).IntValue
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return &err
+}
+
+func (scope *Scope) OpFree() {
+	scope.Stack = scope.Stack[:0]
+}
+
+func (scope *Scope) OpFopen(node AST) (*Error) {
+	return scope.OpenFile(node, "fopen", os.O_CREATE|os.O_RDWR)
+}
+
+// OpFopenRead opens a file for reading only, so a missing file raises
+// FileNotFoundError instead of being created.
+func (scope *Scope) OpFopenRead(node AST) (*Error) {
+	return scope.OpenFile(node, "fopen-read", os.O_RDONLY)
+}
+
+// OpFopenAppend opens a file so that `fwrite` adds to its end.
+func (scope *Scope) OpFopenAppend(node AST) (*Error) {
+	return scope.OpenFile(node, "fopen-append", os.O_CREATE|os.O_WRONLY|os.O_APPEND)
+}
+
+func (scope *Scope) OpenFile(node AST, word string, flag int) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected one or more <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, word)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	FileName := scope.Stack[len(scope.Stack)-1]
+
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := FileName.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, word)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	var file, err = os.OpenFile(FileName.(AsStr).StringValue, flag, 0755)
+
+	if os.IsNotExist(err) && flag&os.O_CREATE == 0 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:FileNotFoundError:%d:%d: `%s` file `%s` not found.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, word, FileName.(AsStr).StringValue)
+		err.Type = FileNotFoundError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	if err != nil {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:FileNotFoundError:%d:%d: `%s` invalid file name `%s`.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, word, FileName.(AsStr).StringValue)
+		err.Type = FileNotFoundError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	FileExpr := AsFile {
+		file,
+		FileName.(AsStr).StringValue,
+	}
+
+	scope.OpPush(FileExpr, nil)
+
+	return nil
+}
+
+func (scope *Scope) OpFclose(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `fclose` expected one or more <file> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	File := scope.Stack[len(scope.Stack)-1]
+
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := File.(AsFile); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `fclose` expected <file> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	File.(AsFile).FileAddress.Close()
+
+	return nil
+}
+
+func (scope *Scope) OpFwrite(node AST) (*Error) {
+	if len(scope.Stack) < 2 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `fwrite` expected type <string> and <file> element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	File := scope.Stack[len(scope.Stack)-1]
+
+	StringValue := scope.Stack[len(scope.Stack)-2]
+
+	scope.Stack = scope.Stack[:len(scope.Stack)-2]
+
+	if _, ok := File.(AsFile); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `fwrite` expected <file> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	if _, ok := StringValue.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `fwrite` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	if _, err := File.(AsFile).FileAddress.WriteString(StringValue.(AsStr).StringValue); err != nil {
+        err := Error{}
+		err.message = fmt.Sprintf("%s:FileNotFoundError:%d:%d: `fopen` invalid file name `%s`.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, File.(AsFile).FileName)
+		err.Type = FileNotFoundError
+		err.Position = node.(AsId).Position
+		return &err
+    }
+
+	return nil
+}
+
+func (scope *Scope) OpFread(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `fread` expected at least one <file> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	File := scope.Stack[len(scope.Stack)-1]
+
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := File.(AsFile); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `fread` expected <file> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	body, err := os.ReadFile(File.(AsFile).FileName)
+
+    if err != nil {
+        err := Error{}
+		err.message = fmt.Sprintf("%s:FileNotFoundError:%d:%d: `fopen` invalid file name `%s`.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, File.(AsFile).FileName)
+		err.Type = FileNotFoundError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StrExpr := AsStr {
+		string(body),
+	}
+
+	scope.OpPush(StrExpr, nil)
+	return nil
+}
+
+func (scope *Scope) OpFtruncate(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `ftruncate` expected at least one <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	File := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := File.(AsFile); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `ftruncate` expected <file> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	// empty the file, and write from its start again
+	err := File.(AsFile).FileAddress.Truncate(0)
+	if err == nil {
+		_, err = File.(AsFile).FileAddress.Seek(0, 0)
+	}
+
+	if err != nil {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:FileNotFoundError:%d:%d: `fopen` invalid file name `%s`.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, File.(AsFile).FileName)
+		err.Type = FileNotFoundError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	return nil
+}
+
+func (scope *Scope) OpIsdigit(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `isdigit` expected at least one <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StringValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := StringValue.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `isdigit` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	_, err := strconv.Atoi(StringValue.(AsStr).StringValue)
+
+	var BoolValue bool
+
+    if err != nil {
+        BoolValue = false
+    } else {
+        BoolValue = true
+    }
+
+	err = nil
+
+	BoolExpr := AsBool {
+		BoolValue,
+	}
+
+	scope.OpPush(BoolExpr, nil)
+
+	return nil
+}
+
+func (scope *Scope) OpAtoi(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `atoi` expected at least one <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StringValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := StringValue.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `atoi` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	IntValue, err := strconv.Atoi(StringValue.(AsStr).StringValue)
+	if err != nil{
+		IntValue = 0
+		if BigValue, ok := new(big.Int).SetString(StringValue.(AsStr).StringValue, 10); ok {
+			scope.OpPush(NormalizeInt(BigValue), nil)
+			return nil
+		}
+	}
+	
+	scope.OpPush(AsInt{IntValue}, nil)
+
+	return nil
+}
+
+func (scope *Scope) OpItoa(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `atoi` expected at least one <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	IntValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if !IsInt(IntValue) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `atoi` expected <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StringValue := FormatValue(IntValue, false)
+	
+	scope.OpPush(AsStr{StringValue}, nil)
+	return nil
+}
+
+func (scope *Scope) OpAtof(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `atof` expected at least one <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StringValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := StringValue.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `atof` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	FloatValue, ParseErr := strconv.ParseFloat(strings.TrimSpace(StringValue.(AsStr).StringValue), 64)
+	if ParseErr != nil {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:ValueError:%d:%d: `atof` cannot convert %s to <float>.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, FormatValue(StringValue, true))
+		err.Type = ValueError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	scope.OpPush(AsFloat{FloatValue}, nil)
+	return nil
+}
+
+func (scope *Scope) OpFtoa(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `ftoa` expected at least one <float> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	FloatValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := FloatValue.(AsFloat); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `ftoa` expected <float> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	scope.OpPush(AsStr{FormatFloat(FloatValue.(AsFloat).FloatValue)}, nil)
+	return nil
+}
+
+func (scope *Scope) OpItof(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `itof` expected at least one <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	IntValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if !IsInt(IntValue) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `itof` expected <int> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	scope.OpPush(AsFloat{ToFloat(IntValue)}, nil)
+	return nil
+}
+
+// OpFtoi truncates a float toward zero. A float outside the range of
+// an int gives a big int.
+func (scope *Scope) OpFtoi(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `ftoi` expected at least one <float> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	FloatValue := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	if _, ok := FloatValue.(AsFloat); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `ftoi` expected <float> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	value := FloatValue.(AsFloat).FloatValue
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:ValueError:%d:%d: `ftoi` cannot convert %s to <int>.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, FormatFloat(value))
+		err.Type = ValueError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	// float64(MaxInt) is 2^63, the first float that does not fit
+	if value >= float64(MinInt) && value < float64(MaxInt) {
+		scope.OpPush(AsInt{int(value)}, nil)
+		return nil
+	}
+	val, _ := big.NewFloat(value).Int(nil)
+	scope.OpPush(NormalizeInt(val), nil)
+	return nil
+}
+
+func (scope *Scope) OpBytes(node AST)(*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `tobyte` expected at least one <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StringValue := scope.Stack[len(scope.Stack)-1]
+
+	if _, ok := StringValue.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `b` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	ByteArray := []byte(StringValue.(AsStr).StringValue)
+
+	NewScope := InitScope()
+
+	for i := 0; i < len(ByteArray); i++ {
+		IntValue, err := strconv.Atoi(fmt.Sprintf("%v", ByteArray[i]))
+		if err != nil{
+			IntValue = 0
+			err = nil
+		}
+		NewScope.Stack = append(NewScope.Stack, AsInt{IntValue})
+	}
+
+	scope.Stack = append(scope.Stack, AsList {NewScope.Stack})
+
+	return nil
+}
+
+func (scope *Scope) OpUniquote(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `uniquote` expected at least one <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StringValue := scope.Stack[len(scope.Stack)-1]
+
+	if _, ok := StringValue.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `uniquote` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	val, _ := strconv.Unquote("\"" + StringValue.(AsStr).StringValue + "\"")
+
+	scope.Stack = append(scope.Stack, AsStr{val})
+
+	return nil
+}
+
+// PopStringArgs pops the arguments of the string words, one for each
+// type of types: `string`, `int`, `list`, `sequence` for a <string> or a
+// <list>, or `any`.
+func (scope *Scope) PopStringArgs(node AST, effect string, types ...string) ([]AST, *Error) {
+	word := node.(AsId).name
+	position := node.(AsId).Position
+	n := len(types)
+	if len(scope.Stack) < n {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected ( %s ) in the stack.", position.FileName, position.Line, position.Column, word, effect)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return nil, &err
+	}
+	args := append([]AST{}, scope.Stack[len(scope.Stack)-n:]...)
+	for i := 0; i < n; i++ {
+		ok := true
+		switch types[i] {
+			case "string": _, ok = args[i].(AsStr)
+			case "int": _, ok = args[i].(AsInt)
+			case "list": _, ok = args[i].(AsList)
+			case "quote": _, ok = args[i].(AsQuote)
+			case "error":
+				_, ok = args[i].(AsError)
+				if kind, IsType := args[i].(AsType); IsType && kind.TypeValue == "error" {
+					ok = true
+				}
+			case "sequence":
+				_, ok = args[i].(AsStr)
+				if _, list := args[i].(AsList); list {
+					ok = true
+				}
+		}
+		if big, IsBig := args[i].(AsBigInt); IsBig && types[i] == "int" {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:ValueError:%d:%d: `%s` expected an <int> from %d to %d, but got %s.", position.FileName, position.Line, position.Column, word, MinInt, MaxInt, big.BigValue)
+			err.Type = ValueError
+			err.Position = position
+			return nil, &err
+		}
+		if !ok {
+			want := "<" + types[i] + ">"
+			if types[i] == "sequence" {
+				want = "<string> or <list>"
+			}
+			err := Error{}
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected %s type element in the stack, but got <%s>.", position.FileName, position.Line, position.Column, word, want, TypeName(args[i]))
+			err.Type = TypeError
+			err.Position = position
+			return nil, &err
+		}
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-n]
+	return args, nil
+}
+
+func (scope *Scope) OpSplit(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<string> <string>", "string", "string")
+	if err != nil {
+		return err
+	}
+	var parts []string
+	if sep := args[1].(AsStr).StringValue; sep == "" {
+		// one element for each character
+		for _, char := range args[0].(AsStr).StringValue {
+			parts = append(parts, string(char))
+		}
+	} else {
+		parts = strings.Split(args[0].(AsStr).StringValue, sep)
+	}
+	list := AsList{[]AST{}}
+	for i := 0; i < len(parts); i++ {
+		list.ListArgs = append(list.ListArgs, AsStr{parts[i]})
+	}
+	scope.Stack = append(scope.Stack, list)
+	return nil
+}
+
+func (scope *Scope) OpJoin(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<list> <string>", "list", "string")
+	if err != nil {
+		return err
+	}
+	parts := []string{}
+	for i := 0; i < len(args[0].(AsList).ListArgs); i++ {
+		parts = append(parts, FormatValue(args[0].(AsList).ListArgs[i], false))
+	}
+	scope.Stack = append(scope.Stack, AsStr{strings.Join(parts, args[1].(AsStr).StringValue)})
+	return nil
+}
+
+// OpSlice pushes the characters of a string, or the elements of a list,
+// from start up to end.
+func (scope *Scope) OpSlice(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<string|list> <int> <int>", "sequence", "int", "int")
+	if err != nil {
+		return err
+	}
+	start, end := args[1].(AsInt).IntValue, args[2].(AsInt).IntValue
+	var runes []rune
+	length := 0
+	if str, ok := args[0].(AsStr); ok {
+		runes = []rune(str.StringValue)
+		length = len(runes)
+	} else {
+		length = len(args[0].(AsList).ListArgs)
+	}
+	if start < 0 || end < start || end > length {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:IndexError:%d:%d: `slice` range %d to %d is out of range for length %d.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, start, end, length)
+		err.Type = IndexError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	if _, ok := args[0].(AsStr); ok {
+		scope.Stack = append(scope.Stack, AsStr{string(runes[start:end])})
+	} else {
+		scope.Stack = append(scope.Stack, AsList{append([]AST{}, args[0].(AsList).ListArgs[start:end]...)})
+	}
+	return nil
+}
+
+// OpFind pushes the index of the first character of the first sub in the
+// string, or -1.
+func (scope *Scope) OpFind(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<string> <string>", "string", "string")
+	if err != nil {
+		return err
+	}
+	str := args[0].(AsStr).StringValue
+	index := strings.Index(str, args[1].(AsStr).StringValue)
+	if index > 0 {
+		index = len([]rune(str[:index]))
+	}
+	scope.Stack = append(scope.Stack, AsInt{index})
+	return nil
+}
+
+func (scope *Scope) OpReplaceAll(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<string> <string> <string>", "string", "string", "string")
+	if err != nil {
+		return err
+	}
+	scope.Stack = append(scope.Stack, AsStr{strings.ReplaceAll(args[0].(AsStr).StringValue, args[1].(AsStr).StringValue, args[2].(AsStr).StringValue)})
+	return nil
+}
+
+// OpStringMap runs trim, upper and lower.
+func (scope *Scope) OpStringMap(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<string>", "string")
+	if err != nil {
+		return err
+	}
+	str := args[0].(AsStr).StringValue
+	switch node.(AsId).name {
+		case "trim": str = strings.TrimSpace(str)
+		case "upper": str = strings.ToUpper(str)
+		case "lower": str = strings.ToLower(str)
+	}
+	scope.Stack = append(scope.Stack, AsStr{str})
+	return nil
+}
+
+// OpAffix runs starts-with and ends-with.
+func (scope *Scope) OpAffix(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<string> <string>", "string", "string")
+	if err != nil {
+		return err
+	}
+	str, affix := args[0].(AsStr).StringValue, args[1].(AsStr).StringValue
+	if node.(AsId).name == "starts-with" {
+		scope.Stack = append(scope.Stack, AsBool{strings.HasPrefix(str, affix)})
+	} else {
+		scope.Stack = append(scope.Stack, AsBool{strings.HasSuffix(str, affix)})
+	}
+	return nil
+}
+
+func (scope *Scope) OpRepeat(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "<string> <int>", "string", "int")
+	if err != nil {
+		return err
+	}
+	if args[1].(AsInt).IntValue < 0 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:IndexError:%d:%d: `repeat` count %d is negative.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, args[1].(AsInt).IntValue)
+		err.Type = IndexError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	if size := len(args[0].(AsStr).StringValue); size > 0 && args[1].(AsInt).IntValue > MaxInt/size {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:ValueError:%d:%d: `repeat` count %d is too large for a string of %d bytes.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, args[1].(AsInt).IntValue, size)
+		err.Type = ValueError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.Stack = append(scope.Stack, AsStr{strings.Repeat(args[0].(AsStr).StringValue, args[1].(AsInt).IntValue)})
+	return nil
+}
+
+// FormatParts splits a format string at its `{}` placeholders. `{{` and
+// `}}` are a `{` and a `}`.
+func FormatParts(format string) []string {
+	parts := []string{}
+	var part strings.Builder
+	for i := 0; i < len(format); i++ {
+		switch {
+			case strings.HasPrefix(format[i:], "{{"):
+				part.WriteByte('{')
+				i++
+			case strings.HasPrefix(format[i:], "}}"):
+				part.WriteByte('}')
+				i++
+			case strings.HasPrefix(format[i:], "{}"):
+				parts = append(parts, part.String())
+				part.Reset()
+				i++
+			default:
+				part.WriteByte(format[i])
+		}
+	}
+	return append(parts, part.String())
+}
+
+// OpFormat fills the `{}` of the format string on top of the stack with
+// the elements below it, the deepest first.
+func (scope *Scope) OpFormat(node AST) (*Error) {
+	args, err := scope.PopStringArgs(node, "a... <string>", "string")
+	if err != nil {
+		return err
+	}
+	parts := FormatParts(args[0].(AsStr).StringValue)
+	n := len(parts) - 1
+	if len(scope.Stack) < n {
+		scope.Stack = append(scope.Stack, args[0])
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `format` expected %d element(s) below the format string, but the stack has %d.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, n, len(scope.Stack)-1)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	values := scope.Stack[len(scope.Stack)-n:]
+	var out strings.Builder
+	for i := 0; i < n; i++ {
+		out.WriteString(parts[i])
+		out.WriteString(FormatValue(values[i], false))
+	}
+	out.WriteString(parts[n])
+	scope.Stack = append(scope.Stack[:len(scope.Stack)-n], AsStr{out.String()})
+	return nil
+}
+
+const ShellToUse = "bash"
+
+func Shellout(command string) (error, string, string) {
+    var stdout bytes.Buffer
+    var stderr bytes.Buffer
+    cmd := exec.Command(ShellToUse, "-c", command)
+    cmd.Stdout = &stdout
+    cmd.Stderr = &stderr
+    err := cmd.Run()
+    return err, stdout.String(), stderr.String()
+}
+
+func (scope *Scope) OpSystem(node AST) (*Error) {
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `system` expected at least one <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = StackUnderflowError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	StringValue := scope.Stack[len(scope.Stack)-1]
+
+	if _, ok := StringValue.(AsStr); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `system` expected <string> type element in the stack.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = TypeError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+
+	err, out, _ := Shellout(StringValue.(AsStr).StringValue)
+
+    if err != nil {
+        err := Error{}
+		err.message = fmt.Sprintf("%s:CommandError:%d:%d: `system` something whent wrong...", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column)
+		err.Type = CommandError
+		err.Position = node.(AsId).Position
+		return &err
+    }
+
+    fmt.Fprint(scope.Out, out)
+	
+	return nil
+}
+
+func (scope *Scope) OpAgrv(args []string) {
+	NewScope := InitScope()
+	for i := 0; i < len(args); i++ {
+		NewScope.OpPush(AsStr{args[i]}, nil)
+	}
+	scope.Variables["argv"] = AsList{NewScope.Stack}
+}
+
+// MapKey returns the key of a value in AsMap.Index. Only values that
+// compare by value can be keys. A float with no fraction has the key of
+// the int it equals, as `1 1.0 ==` is true.
+func MapKey(node AST) (string, bool) {
+	switch node.(type) {
+		case AsFloat:
+			value := node.(AsFloat).FloatValue
+			if !math.IsInf(value, 0) && math.Trunc(value) == value {
+				val, _ := big.NewFloat(value).Int(nil)
+				return TypeName(NormalizeInt(val)) + ":" + val.String(), true
+			}
+			return TypeName(node) + ":" + FormatValue(node, true), true
+		case AsStr, AsInt, AsBigInt, AsBool, AsType:
+			return TypeName(node) + ":" + FormatValue(node, true), true
+	}
+	return "", false
+}
+
+func MapKeyError(what string, key AST, position NodePosition) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:TypeError:%d:%d: %s key expected <string>, <int>, <float>, <bool> or <type> type, but got <%s>.", position.FileName, position.Line, position.Column, what, TypeName(key))
+	err.Type = TypeError
+	err.Position = position
+	return &err
+}
+
+// BuildMap makes the map of a `[ key value ... ]` literal.
+func BuildMap(elements []AST, position NodePosition) (AST, *Error) {
+	if len(elements) % 2 != 0 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: map literal expected a value for every key, but got %d element(s).", position.FileName, position.Line, position.Column, len(elements))
+		err.Type = StackUnderflowError
+		err.Position = position
+		return nil, &err
+	}
+	value := AsMap{Index: map[string]int{}}
+	for i := 0; i < len(elements); i += 2 {
+		key, ok := MapKey(elements[i])
+		if !ok {
+			return nil, MapKeyError("map literal", elements[i], position)
+		}
+		if index, ok := value.Index[key]; ok {
+			value.Values[index] = elements[i+1]
+			continue
+		}
+		value.Index[key] = len(value.Keys)
+		value.Keys = append(value.Keys, elements[i])
+		value.Values = append(value.Values, elements[i+1])
+	}
+	return value, nil
+}
+
+func (value AsMap) Copy() AsMap {
+	NewMap := AsMap{
+		append([]AST{}, value.Keys...),
+		append([]AST{}, value.Values...),
+		make(map[string]int, len(value.Index)),
+	}
+	for key, index := range value.Index {
+		NewMap.Index[key] = index
+	}
+	return NewMap
+}
+
+func MapEqual(a AsMap, b AsMap) bool {
+	if len(a.Keys) != len(b.Keys) {
+		return false
+	}
+	for key, index := range a.Index {
+		other, ok := b.Index[key]
+		if !ok || !ValuesEqual(a.Values[index], b.Values[other]) {
+			return false
+		}
+	}
+	return true
+}
+
+// PopMapArgs pops the map and the key of the map words, with the n - 2
+// other arguments above them.
+func (scope *Scope) PopMapArgs(node AST, n int, effect string) (AsMap, string, []AST, *Error) {
+	word := node.(AsId).name
+	position := node.(AsId).Position
+	if len(scope.Stack) < n {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected ( %s ) in the stack.", position.FileName, position.Line, position.Column, word, effect)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return AsMap{}, "", nil, &err
+	}
+	args := scope.Stack[len(scope.Stack)-n:]
+	if _, ok := args[0].(AsMap); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected <map> type element in the stack, but got <%s>.", position.FileName, position.Line, position.Column, word, TypeName(args[0]))
+		err.Type = TypeError
+		err.Position = position
+		return AsMap{}, "", nil, &err
+	}
+	key, ok := MapKey(args[1])
+	if !ok {
+		return AsMap{}, "", nil, MapKeyError("`" + word + "` map", args[1], position)
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-n]
+	return args[0].(AsMap), key, args[1:], nil
+}
+
+func (scope *Scope) OpGet(node AST) (*Error) {
+	value, key, args, err := scope.PopMapArgs(node, 2, "<map> key")
+	if err != nil {
+		return err
+	}
+	index, ok := value.Index[key]
+	if !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:IndexError:%d:%d: `get` key %s not found in the map.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, FormatValue(args[0], true))
+		err.Type = IndexError
+		err.Position = node.(AsId).Position
+		return &err
+	}
+	scope.OpPush(value.Values[index], nil)
+	return nil
+}
+
+func (scope *Scope) OpSet(node AST) (*Error) {
+	value, key, args, err := scope.PopMapArgs(node, 3, "<map> key value")
+	if err != nil {
+		return err
+	}
+	NewMap := value.Copy()
+	if index, ok := NewMap.Index[key]; ok {
+		NewMap.Values[index] = args[1]
+	} else {
+		NewMap.Index[key] = len(NewMap.Keys)
+		NewMap.Keys = append(NewMap.Keys, args[0])
+		NewMap.Values = append(NewMap.Values, args[1])
+	}
+	scope.OpPush(NewMap, nil)
+	return nil
+}
+
+func (scope *Scope) OpDelete(node AST) (*Error) {
+	value, key, _, err := scope.PopMapArgs(node, 2, "<map> key")
+	if err != nil {
+		return err
+	}
+	index, ok := value.Index[key]
+	if !ok {
+		scope.OpPush(value, nil)
+		return nil
+	}
+	NewMap := AsMap{Index: map[string]int{}}
+	for i := 0; i < len(value.Keys); i++ {
+		if i == index {
+			continue
+		}
+		k, _ := MapKey(value.Keys[i])
+		NewMap.Index[k] = len(NewMap.Keys)
+		NewMap.Keys = append(NewMap.Keys, value.Keys[i])
+		NewMap.Values = append(NewMap.Values, value.Values[i])
+	}
+	scope.OpPush(NewMap, nil)
+	return nil
+}
+
+func (scope *Scope) OpHasKey(node AST) (*Error) {
+	value, key, _, err := scope.PopMapArgs(node, 2, "<map> key")
+	if err != nil {
+		return err
+	}
+	_, ok := value.Index[key]
+	scope.OpPush(AsBool{ok}, nil)
+	return nil
+}
+
+func (scope *Scope) PopMap(node AST) (AsMap, *Error) {
+	position := node.(AsId).Position
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected one or more <map> type element in the stack.", position.FileName, position.Line, position.Column, node.(AsId).name)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return AsMap{}, &err
+	}
+	value := scope.Stack[len(scope.Stack)-1]
+	if _, ok := value.(AsMap); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected <map> type element in the stack, but got <%s>.", position.FileName, position.Line, position.Column, node.(AsId).name, TypeName(value))
+		err.Type = TypeError
+		err.Position = position
+		return AsMap{}, &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return value.(AsMap), nil
+}
+
+func (scope *Scope) OpKeys(node AST) (*Error) {
+	value, err := scope.PopMap(node)
+	if err != nil {
+		return err
+	}
+	scope.OpPush(AsList{append([]AST{}, value.Keys...)}, nil)
+	return nil
+}
+
+func (scope *Scope) OpValues(node AST) (*Error) {
+	value, err := scope.PopMap(node)
+	if err != nil {
+		return err
+	}
+	scope.OpPush(AsList{append([]AST{}, value.Values...)}, nil)
+	return nil
+}
+
+func (scope *Scope) PopQuote(node AST) (AsQuote, *Error) {
+	position := node.(AsId).Position
+	if len(scope.Stack) < 1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected one or more <quote> type element in the stack.", position.FileName, position.Line, position.Column, node.(AsId).name)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return AsQuote{}, &err
+	}
+	value := scope.Stack[len(scope.Stack)-1]
+	if _, ok := value.(AsQuote); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected <quote> type element in the stack, but got <%s>.", position.FileName, position.Line, position.Column, node.(AsId).name, TypeName(value))
+		err.Type = TypeError
+		err.Position = position
+		return AsQuote{}, &err
+	}
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return value.(AsQuote), nil
+}
+
+// PopQuoteArgs pops the quotation of `collect`, `filter`, `each` and
+// `reduce`, and the list below it.
+func (scope *Scope) PopQuoteArgs(node AST, n int, effect string) (AsQuote, []AST, *Error) {
+	position := node.(AsId).Position
+	word := node.(AsId).name
+	if len(scope.Stack) < n {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` expected ( %s ) in the stack.", position.FileName, position.Line, position.Column, word, effect)
+		err.Type = StackUnderflowError
+		err.Position = position
+		return AsQuote{}, nil, &err
+	}
+	list := scope.Stack[len(scope.Stack)-n]
+	if _, ok := list.(AsList); !ok {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` expected <list> type element in the stack, but got <%s>.", position.FileName, position.Line, position.Column, word, TypeName(list))
+		err.Type = TypeError
+		err.Position = position
+		return AsQuote{}, nil, &err
+	}
+	quote, err := scope.PopQuote(node)
+	if err != nil {
+		return AsQuote{}, nil, err
+	}
+	scope.Stack = append(scope.Stack[:len(scope.Stack)-n+1], scope.Stack[len(scope.Stack)-n+2:]...)
+	return quote, list.(AsList).ListArgs, nil
+}
+
+// CallForOne runs the quotation on one element, and returns the one
+// element the quotation must leave.
+func (scope *Scope) CallForOne(node AST, quote AsQuote, element AST) (AST, *Error) {
+	position := node.(AsId).Position
+	depth := len(scope.Stack)
+	scope.Stack = append(scope.Stack, element)
+	if err := scope.Call(quote.Block, position); err != nil {
+		return nil, err
+	}
+	if len(scope.Stack) != depth+1 {
+		err := Error{}
+		err.message = fmt.Sprintf("%s:StackUnderflowError:%d:%d: `%s` quotation should leave 1 element in the stack, but left %d.", position.FileName, position.Line, position.Column, node.(AsId).name, len(scope.Stack)-depth)
+		err.Type = StackUnderflowError
+		if len(scope.Stack) > depth+1 {
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` quotation should leave 1 element in the stack, but left %d.", position.FileName, position.Line, position.Column, node.(AsId).name, len(scope.Stack)-depth)
+			err.Type = TypeError
+		}
+		err.Position = position
+		return nil, &err
+	}
+	value := scope.Stack[len(scope.Stack)-1]
+	scope.Stack = scope.Stack[:len(scope.Stack)-1]
+	return value, nil
+}
+
+func (scope *Scope) OpCallQuote(node AST) (*Error) {
+	quote, err := scope.PopQuote(node)
+	if err != nil {
+		return err
+	}
+	return scope.Call(quote.Block, node.(AsId).Position)
+}
+
+func (scope *Scope) OpCollect(node AST) (*Error) {
+	quote, elements, err := scope.PopQuoteArgs(node, 2, "<list> <quote>")
+	if err != nil {
+		return err
+	}
+	result := []AST{}
+	for i := 0; i < len(elements); i++ {
+		value, err := scope.CallForOne(node, quote, elements[i])
+		if err != nil {
+			return err
+		}
+		result = append(result, value)
+	}
+	scope.OpPush(AsList{result}, nil)
+	return nil
+}
+
+func (scope *Scope) OpFilter(node AST) (*Error) {
+	quote, elements, err := scope.PopQuoteArgs(node, 2, "<list> <quote>")
+	if err != nil {
+		return err
+	}
+	result := []AST{}
+	for i := 0; i < len(elements); i++ {
+		value, err := scope.CallForOne(node, quote, elements[i])
+		if err != nil {
+			return err
+		}
+		if _, ok := value.(AsBool); !ok {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: `filter` quotation should leave a <bool> type element in the stack, but left <%s>.", node.(AsId).Position.FileName, node.(AsId).Position.Line, node.(AsId).Position.Column, TypeName(value))
+			err.Type = TypeError
+			err.Position = node.(AsId).Position
+			return &err
+		}
+		if value.(AsBool).BoolValue {
+			result = append(result, elements[i])
+		}
+	}
+	scope.OpPush(AsList{result}, nil)
+	return nil
+}
+
+func (scope *Scope) OpEach(node AST) (*Error) {
+	quote, elements, err := scope.PopQuoteArgs(node, 2, "<list> <quote>")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(elements); i++ {
+		scope.Stack = append(scope.Stack, elements[i])
+		if err := scope.Call(quote.Block, node.(AsId).Position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpReduce leaves the initial value on the stack, then pushes each
+// element and runs the quotation, so the quotation folds the element
+// into the value below it.
+func (scope *Scope) OpReduce(node AST) (*Error) {
+	quote, elements, err := scope.PopQuoteArgs(node, 3, "<list> a <quote>")
+	if err != nil {
+		return err
+	}
+	for i := 0; i < len(elements); i++ {
+		scope.Stack = append(scope.Stack, elements[i])
+		if err := scope.Call(quote.Block, node.(AsId).Position); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// LookupMember reads `module.name` from the module value of the Var.
+func LookupMember(value AST, node Var) (AST, *Error) {
+	position := node.Position
+	path := node.Name
+	names := strings.Split(node.Member, ".")
+	for i := 0; i < len(names); i++ {
+		module, ok := value.(AsModule)
+		if !ok {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:TypeError:%d:%d: `%s` is not a module, but <%s>.", position.FileName, position.Line, position.Column, path, TypeName(value))
+			err.Type = TypeError
+			err.Position = position
+			return nil, &err
+		}
+		if value, ok = module.Member(names[i]); !ok {
+			err := Error{}
+			err.message = fmt.Sprintf("%s:NameError:%d:%d: module `%s` has no name `%s`.", position.FileName, position.Line, position.Column, path, names[i])
+			err.Type = NameError
+			err.Position = position
+			return nil, &err
+		}
+		path = path + "." + names[i]
+	}
+	return value, nil
+}
+
+func (module AsModule) Member(name string) (AST, bool) {
+	if module.Env.Vars != nil {
+		value, ok := module.Env.Vars[name]
+		return value, ok
+	}
+	for i := 0; i < len(module.Names); i++ {
+		if module.Names[i] == name && module.Env.Slots[i] != nil {
+			return module.Env.Slots[i], true
+		}
+	}
+	return nil, false
+}
+
+func ImportErr(position NodePosition, format string, args ...interface{}) (*Error) {
+	err := Error{}
+	err.message = fmt.Sprintf("%s:ImportError:%d:%d: %s", position.FileName, position.Line, position.Column, fmt.Sprintf(format, args...))
+	err.Type = ImportError
+	err.Position = position
+	return &err
+}
+
+// ResolveImport finds the file of `import "name"`: in the standard
+// library, then next to the importing file, then in the package whose
+// name starts it, then in the directories of Modules.Path and of
+// TSH_PATH. A name without an extension gets `.tsp`. It returns the path
+// of the file, and its absolute path that identifies the module.
+func (scope *Scope) ResolveImport(name string, position NodePosition) (string, string, *Error) {
+	parts := strings.SplitN(name, "/", 2)
+	if filepath.Ext(name) == "" {
+		name = name + ".tsp"
+	}
+	// `./name` is the way to a file that has the name of a module of the
+	// standard library
+	if !strings.HasPrefix(name, ".") && !filepath.IsAbs(name) {
+		if path, ok := StdlibFile(name); ok {
+			return path, path, nil
+		}
+	}
+	candidates := []string{name}
+	if !filepath.IsAbs(name) {
+		candidates = []string{filepath.Join(filepath.Dir(position.FileName), name)}
+		if !FileExists(candidates[0]) {
+			if err := scope.Modules.LoadManifest(); err != nil {
+				return "", "", err
+			}
+		}
+		if pkg, ok := scope.Modules.Packages[parts[0]]; ok {
+			if len(parts) == 1 {
+				candidates = append(candidates, filepath.Join(pkg.Root, pkg.Main))
+			} else {
+				candidates = append(candidates, filepath.Join(pkg.Root, strings.TrimPrefix(name, parts[0]+"/")))
+			}
+		}
+		dirs := append(append([]string{}, scope.Modules.Path...), filepath.SplitList(os.Getenv("TSH_PATH"))...)
+		for i := 0; i < len(dirs); i++ {
+			if dirs[i] != "" {
+				candidates = append(candidates, filepath.Join(dirs[i], name))
+			}
+		}
+	}
+	for i := 0; i < len(candidates); i++ {
+		if info, err := os.Stat(candidates[i]); err == nil && !info.IsDir() {
+			key, err := filepath.Abs(candidates[i])
+			if err != nil {
+				key = filepath.Clean(candidates[i])
+			}
+			return candidates[i], key, nil
+		}
+	}
+	return "", "", ImportErr(position, "module `%s` not found.", name)
+}
+
+// OpImport pushes the module of an `import`. The file of a module runs
+// the first time it is imported, on a stack of its own, and later
+// imports get the same module. run runs it with the engine of the scope.
+func (scope *Scope) OpImport(node Import, run func(ast AST, path string) (AsModule, *Error)) (*Error) {
+	path, key, err := scope.ResolveImport(node.Path, node.Position)
+	if err != nil {
+		return err
+	}
+	if module, ok := scope.Modules.Loaded[key]; ok {
+		scope.Stack = append(scope.Stack, module)
+		return nil
+	}
+	for i := 0; i < len(scope.Modules.Loading); i++ {
+		if scope.Modules.Loading[i].Key == key {
+			chain := []string{}
+			for j := i; j < len(scope.Modules.Loading); j++ {
+				chain = append(chain, scope.Modules.Loading[j].Path)
+			}
+			return ImportErr(node.Position, "import cycle: %s -> %s.", strings.Join(chain, " -> "), path)
+		}
+	}
+	ast, err := ParseModule(path, node.Position)
+	if err != nil {
+		return err
+	}
+	scope.Modules.Loading = append(scope.Modules.Loading, ModuleFile{key, path, ModuleName(node.Path)})
+	module, err := run(ast, path)
+	scope.Modules.Loading = scope.Modules.Loading[:len(scope.Modules.Loading)-1]
+	if err != nil {
+		return err
+	}
+	module.Name = ModuleName(node.Path)
+	module.Path = path
+	scope.Modules.Loaded[key] = module
+	scope.Stack = append(scope.Stack, module)
+	return nil
+}
+
+// RunModule runs the file of a module for the tree walker.
+func (scope *Scope) RunModule(ast AST, path string) (AsModule, *Error) {
+	ModuleScope := scope.SubScope()
+	VariableScope := &Env{Vars: map[string]AST{}}
+	_, err, _ := ModuleScope.VisitorVisit(ast, VariableScope)
+	if err != nil {
+		err.SetTrace(*scope.Trace, ModuleScope.Stack)
+	}
+	return AsModule{Env: VariableScope}, err
+}
@@ -0,0 +1,80 @@
+package tsharp
+
+import (
+	"embed"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+
+// -----------------------------
+// ---------- Stdlib -----------
+// -----------------------------
+
+//go:embed stdlib/*.tsp
+var Stdlib embed.FS
+
+// StdlibDir is the directory the files of the standard library are shown
+// in. They are not on disk: `import "lists"` reads them from the binary.
+const StdlibDir = "$std"
+
+// StdlibFile returns the path of the standard library module named by an
+// import, if there is one.
+func StdlibFile(name string) (string, bool) {
+	name = filepath.ToSlash(name)
+	if !fs.ValidPath(name) {
+		return "", false
+	}
+	if _, err := fs.Stat(Stdlib, path.Join("stdlib", name)); err != nil {
+		return "", false
+	}
+	return filepath.Join(StdlibDir, filepath.FromSlash(name)), true
+}
+
+func IsStdlibFile(FileName string) bool {
+	return strings.HasPrefix(FileName, StdlibDir+string(filepath.Separator))
+}
+
+// StdlibModules are the names of the modules of the standard library.
+func StdlibModules() []string {
+	names := []string{}
+	entries, _ := fs.ReadDir(Stdlib, "stdlib")
+	for i := 0; i < len(entries); i++ {
+		names = append(names, strings.TrimSuffix(entries[i].Name(), ".tsp"))
+	}
+	return names
+}
+
+// StdlibName is the name a file of the standard library is imported by.
+func StdlibName(FileName string) string {
+	return filepath.ToSlash(strings.TrimPrefix(FileName, StdlibDir+string(filepath.Separator)))
+}
+
+// StdlibSource returns the code of a file of the standard library.
+func StdlibSource(FileName string) (string, bool) {
+	source, err := Stdlib.ReadFile(path.Join("stdlib", StdlibName(FileName)))
+	if err != nil {
+		return "", false
+	}
+	return string(source), true
+}
+
+// ParseModule parses the file of a module, on disk or in the standard
+// library.
+func ParseModule(FileName string, position NodePosition) (AST, *Error) {
+	if !IsStdlibFile(FileName) {
+		return ParseInclude(FileName, position)
+	}
+	source, ok := StdlibSource(FileName)
+	if !ok {
+		return nil, ImportErr(position, "module `%s` not found.", StdlibName(FileName))
+	}
+	lexer := LexerInit(strings.NewReader(source), FileName)
+	parser, ParseErr := ParserInit(lexer)
+	if ParseErr != nil {
+		return nil, ParseErr
+	}
+	return ParserParse(parser)
+}
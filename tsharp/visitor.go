@@ -0,0 +1,133 @@
+package tsharp
+
+
+// -----------------------------
+// --------- Visitor -----------
+// -----------------------------
+
+func (scope *Scope) VisitorVisit(node AST, VariableScope *Env) (bool, *Error, *Env) {
+	BreakValue := false
+	var err *Error
+	for i := 0; i < len(node.(AsStatements)); i++ {
+		node := node.(AsStatements)[i]
+		switch node.(type) {
+			case AsPush:
+				err = scope.OpPush(node.(AsPush).value, VariableScope)
+			case AsId:
+				switch node.(AsId).name {
+					case "println": err = scope.OpPrintln(node)
+					case "print": err = scope.OpPrint(node)
+					case "break": BreakValue = true
+					case "drop": err = scope.OpDrop(node)
+					case "swap": err = scope.OpSwap(node)
+					case "inc": err = scope.OpInc(node)
+					case "dec": err = scope.OpDec(node)
+					case "dup": err = scope.OpDup(node)
+					case "append": err = scope.OpAppend(node)
+					case "read": err = scope.OpRead(node)
+					case "replace": err = scope.OpReplace(node)
+					case "remove": err = scope.OpRemove(node)
+					case "in": err = scope.OpIn(node)
+					case "len": err = scope.OpLen(node)
+					case "typeof": err = scope.OpTypeOf(node)
+					case "rot": err = scope.OpRot(node)
+					case "over": err = scope.OpOver(node)
+					case "exit": err = scope.OpExit(node)
+					case "input": scope.OpInput()
+					case "free": scope.OpFree()
+					case "fopen": err = scope.OpFopen(node)
+					case "fopen-read": err = scope.OpFopenRead(node)
+					case "fopen-append": err = scope.OpFopenAppend(node)
+					case "fwrite": err = scope.OpFwrite(node)
+					case "fclose": err = scope.OpFclose(node)
+					case "fread": err = scope.OpFread(node)
+					case "ftruncate": err = scope.OpFtruncate(node)
+					case "isdigit": err = scope.OpIsdigit(node)
+					case "atoi": err = scope.OpAtoi(node)
+					case "itoa": err = scope.OpItoa(node)
+					case "atof": err = scope.OpAtof(node)
+					case "ftoa": err = scope.OpFtoa(node)
+					case "itof": err = scope.OpItof(node)
+					case "ftoi": err = scope.OpFtoi(node)
+					case "b": err = scope.OpBytes(node)
+					case "uniquote": err = scope.OpUniquote(node)
+					case "split": err = scope.OpSplit(node)
+					case "join": err = scope.OpJoin(node)
+					case "slice": err = scope.OpSlice(node)
+					case "find": err = scope.OpFind(node)
+					case "replace-all": err = scope.OpReplaceAll(node)
+					case "trim", "upper", "lower": err = scope.OpStringMap(node)
+					case "starts-with", "ends-with": err = scope.OpAffix(node)
+					case "repeat": err = scope.OpRepeat(node)
+					case "format": err = scope.OpFormat(node)
+					case "system": err = scope.OpSystem(node)
+					case "raise": err = scope.OpRaise(node)
+					case "rethrow": err = scope.OpRethrow(node)
+					case "errmsg": err = scope.OpErrmsg(node)
+					case "assert-equal": err = scope.OpAssertEqual(node)
+					case "assert-not-equal": err = scope.OpAssertNotEqual(node)
+					case "assert-depth": err = scope.OpAssertDepth(node)
+					case "assert-raises": err = scope.OpAssertRaises(node)
+					case "get": err = scope.OpGet(node)
+					case "set": err = scope.OpSet(node)
+					case "delete": err = scope.OpDelete(node)
+					case "has-key": err = scope.OpHasKey(node)
+					case "keys": err = scope.OpKeys(node)
+					case "values": err = scope.OpValues(node)
+					case "call":
+						if node.(AsId).Tail && VariableScope != nil {
+							var quote AsQuote
+							if quote, err = scope.PopQuote(node); err == nil {
+								VariableScope.SetTail(quote.Block, node.(AsId).Position)
+							}
+							break
+						}
+						err = scope.OpCallQuote(node)
+					case "collect": err = scope.OpCollect(node)
+					case "filter": err = scope.OpFilter(node)
+					case "each": err = scope.OpEach(node)
+					case "reduce": err = scope.OpReduce(node)
+					default: panic("unreachable")
+				}
+			case AsBinop:
+				err = scope.OpBinop(node.(AsBinop).op, node.(AsBinop).Position)
+			case Vardef:
+				if node.(Vardef).Outer {
+					err = scope.OpAssign(node.(Vardef).Name, node.(Vardef).Position, VariableScope)
+				} else {
+					err = scope.OpVardef(node.(Vardef).Name, node.(Vardef).Position, VariableScope)
+				}
+			case Blockdef:
+				err = scope.OpBlockdef(node, VariableScope)
+			case Errordef:
+				err = scope.OpErrordef(node, VariableScope)
+			case Include:
+				err = scope.OpInclude(node.(Include).FileName, node.(Include).Position)
+			case Import:
+				if err = scope.OpImport(node.(Import), scope.RunModule); err == nil {
+					err = scope.OpVardef(node.(Import).Name, node.(Import).Position, VariableScope)
+				}
+			case Compare:
+				err = scope.OpCompare(node.(Compare).op, node.(Compare).Position)
+			case AsStatements:
+				_, err, _ = scope.VisitorVisit(node.(AsStatements), VariableScope)
+			case If:
+				BreakValue, err = scope.OpIf(node.(If), VariableScope)
+			case For:
+				err = scope.OpFor(node.(For), VariableScope)
+			case Try:
+				BreakValue, err = scope.OpTry(node.(Try), VariableScope)
+			case Assert:
+				err = scope.OpAssert(node)
+			default:
+				panic("unreachable")
+		}
+		if err != nil {
+			return BreakValue, err, VariableScope
+		}
+		if BreakValue {
+			break
+		}
+	}
+	return BreakValue, err, VariableScope
+}
@@ -0,0 +1,573 @@
+package tsharp
+
+import (
+	"sync/atomic"
+)
+
+
+// -----------------------------
+// ------------ VM -------------
+// -----------------------------
+
+type Word func(scope *Scope, node AST) (*Error)
+
+// Words are the built-in words the VM calls through OP_WORD. `break` is
+// not a word, it compiles to a jump, and `call` compiles to OP_CALL.
+var Words = map[string]Word{
+	"println": (*Scope).OpPrintln,
+	"print": (*Scope).OpPrint,
+	"drop": (*Scope).OpDrop,
+	"swap": (*Scope).OpSwap,
+	"inc": (*Scope).OpInc,
+	"dec": (*Scope).OpDec,
+	"dup": (*Scope).OpDup,
+	"append": (*Scope).OpAppend,
+	"read": (*Scope).OpRead,
+	"replace": (*Scope).OpReplace,
+	"remove": (*Scope).OpRemove,
+	"in": (*Scope).OpIn,
+	"len": (*Scope).OpLen,
+	"typeof": (*Scope).OpTypeOf,
+	"rot": (*Scope).OpRot,
+	"over": (*Scope).OpOver,
+	"exit": (*Scope).OpExit,
+	"input": func(scope *Scope, node AST) (*Error) { scope.OpInput(); return nil },
+	"free": func(scope *Scope, node AST) (*Error) { scope.OpFree(); return nil },
+	"fopen": (*Scope).OpFopen,
+	"fopen-read": (*Scope).OpFopenRead,
+	"fopen-append": (*Scope).OpFopenAppend,
+	"fwrite": (*Scope).OpFwrite,
+	"fclose": (*Scope).OpFclose,
+	"fread": (*Scope).OpFread,
+	"ftruncate": (*Scope).OpFtruncate,
+	"isdigit": (*Scope).OpIsdigit,
+	"atoi": (*Scope).OpAtoi,
+	"itoa": (*Scope).OpItoa,
+	"atof": (*Scope).OpAtof,
+	"ftoa": (*Scope).OpFtoa,
+	"itof": (*Scope).OpItof,
+	"ftoi": (*Scope).OpFtoi,
+	"b": (*Scope).OpBytes,
+	"uniquote": (*Scope).OpUniquote,
+	"split": (*Scope).OpSplit,
+	"join": (*Scope).OpJoin,
+	"slice": (*Scope).OpSlice,
+	"find": (*Scope).OpFind,
+	"replace-all": (*Scope).OpReplaceAll,
+	"trim": (*Scope).OpStringMap,
+	"upper": (*Scope).OpStringMap,
+	"lower": (*Scope).OpStringMap,
+	"starts-with": (*Scope).OpAffix,
+	"ends-with": (*Scope).OpAffix,
+	"repeat": (*Scope).OpRepeat,
+	"format": (*Scope).OpFormat,
+	"system": (*Scope).OpSystem,
+	"raise": (*Scope).OpRaise,
+	"rethrow": (*Scope).OpRethrow,
+	"errmsg": (*Scope).OpErrmsg,
+	"assert-equal": (*Scope).OpAssertEqual,
+	"assert-not-equal": (*Scope).OpAssertNotEqual,
+	"assert-depth": (*Scope).OpAssertDepth,
+	"assert-raises": (*Scope).OpAssertRaises,
+	"get": (*Scope).OpGet,
+	"set": (*Scope).OpSet,
+	"delete": (*Scope).OpDelete,
+	"has-key": (*Scope).OpHasKey,
+	"keys": (*Scope).OpKeys,
+	"collect": (*Scope).OpCollect,
+	"filter": (*Scope).OpFilter,
+	"each": (*Scope).OpEach,
+	"reduce": (*Scope).OpReduce,
+	"values": (*Scope).OpValues,
+}
+
+type Frame struct {
+	Chunk *Chunk
+	Ip int
+	Env *Env
+	Block *Blockdef
+	Position NodePosition
+	Depth int
+	Calls int
+	Checks []OutputCheck
+	Lists int
+}
+
+// Handler is an active try statement. Frame, Lists and Pending are the
+// sizes of the VM stacks to go back to when it catches an error.
+type Handler struct {
+	Target int
+	Frame int
+	Lists int
+	Pending int
+}
+
+// VM runs compiled chunks on a Scope. Global variables are resolved to
+// slots at compile time; Globals mirrors scope.Variables for the names
+// the VM has seen, and every write goes to both.
+type VM struct {
+	Id int
+	Scope *Scope
+	Names []string
+	Slots map[string]int
+	Globals []AST
+	Frames []Frame
+	Handlers []Handler
+	Lists [][]AST
+	Pending []*Error
+}
+
+// BreakPending is pending for the finally body of a try statement that a
+// `break` leaves, in place of an error.
+var BreakPending = &Error{}
+
+var VMCount int64
+
+func NewVM(scope *Scope) *VM {
+	return &VM{
+		Id: int(atomic.AddInt64(&VMCount, 1)),
+		Scope: scope,
+		Slots: map[string]int{},
+	}
+}
+
+func (vm *VM) Slot(name string) int {
+	if slot, ok := vm.Slots[name]; ok {
+		return slot
+	}
+	vm.Slots[name] = len(vm.Names)
+	vm.Names = append(vm.Names, name)
+	vm.Globals = append(vm.Globals, vm.Scope.Variables[name])
+	return len(vm.Names)-1
+}
+
+// Load reads the global slots again, since the host may have changed
+// scope.Variables between two runs.
+func (vm *VM) Load() {
+	for i := 0; i < len(vm.Names); i++ {
+		vm.Globals[i] = vm.Scope.Variables[vm.Names[i]]
+	}
+}
+
+func (vm *VM) SetGlobal(slot int, value AST) {
+	vm.Globals[slot] = value
+	vm.Scope.Variables[vm.Names[slot]] = value
+}
+
+// Lookup reads a name through its ref: the first local slot that is set,
+// from the innermost block out, or else the global.
+func (vm *VM) Lookup(global int, ref int) AST {
+	if ref >= 0 {
+		frame := &vm.Frames[len(vm.Frames)-1]
+		if slot := vm.RefSlot(frame, ref); slot != nil {
+			return *slot
+		}
+	}
+	return vm.Globals[global]
+}
+
+// RefSlot finds the first local slot of the ref that is set.
+func (vm *VM) RefSlot(frame *Frame, ref int) *AST {
+	pairs := frame.Chunk.Refs[ref]
+	for i := 0; i < len(pairs); i++ {
+		env := frame.Env
+		for depth := 0; depth < pairs[i][0] && env != nil; depth++ {
+			env = env.Outer
+		}
+		if env == nil || pairs[i][1] >= len(env.Slots) {
+			continue
+		}
+		if env.Slots[pairs[i][1]] != nil {
+			return &env.Slots[pairs[i][1]]
+		}
+	}
+	return nil
+}
+
+// Assign stores a value like OpVardef: in a block the name is local,
+// outside it is global.
+func (vm *VM) Assign(global int, local int, value AST) {
+	env := vm.Frames[len(vm.Frames)-1].Env
+	if local < 0 || env == nil {
+		vm.SetGlobal(global, value)
+		return
+	}
+	env.Slots[local] = value
+}
+
+func (vm *VM) Pop() AST {
+	value := vm.Scope.Stack[len(vm.Scope.Stack)-1]
+	vm.Scope.Stack = vm.Scope.Stack[:len(vm.Scope.Stack)-1]
+	return value
+}
+
+func (vm *VM) BlockChunk(block Blockdef) *Chunk {
+	if block.Code != nil && block.Code.Owner == vm.Id {
+		return block.Code
+	}
+	return vm.CompileBlock(block, nil)
+}
+
+// Execute runs a compiled program. After an uncaught error the stack is
+// left as VisitorVisit would leave it.
+func (vm *VM) Execute(chunk *Chunk) (*Error) {
+	vm.Load()
+	vm.Scope.Call = vm.CallBlock
+	vm.Frames = append(vm.Frames, Frame{Chunk: chunk})
+	err := vm.Run(0)
+	if err != nil {
+		if len(vm.Lists) > 0 {
+			vm.Scope.Stack = vm.Lists[0]
+		}
+		vm.Frames = vm.Frames[:0]
+		vm.Handlers = vm.Handlers[:0]
+		vm.Lists = vm.Lists[:0]
+		vm.Pending = vm.Pending[:0]
+	}
+	return err
+}
+
+// Throw hands an error to the innermost try statement of the frames above
+// base, or returns it when there is none.
+func (vm *VM) Throw(err *Error, base int) (*Error) {
+	if len(vm.Handlers) == 0 || vm.Handlers[len(vm.Handlers)-1].Frame < base {
+		err.SetTrace(vm.Trace(), vm.FailedStack())
+		return err
+	}
+	handler := vm.Handlers[len(vm.Handlers)-1]
+	vm.Handlers = vm.Handlers[:len(vm.Handlers)-1]
+	vm.Frames = vm.Frames[:handler.Frame+1]
+	if len(vm.Lists) > handler.Lists {
+		vm.Scope.Stack = vm.Lists[handler.Lists]
+		vm.Lists = vm.Lists[:handler.Lists]
+	}
+	vm.Pending = append(vm.Pending[:handler.Pending], err)
+	vm.Frames[handler.Frame].Ip = handler.Target
+	return nil
+}
+
+func (vm *VM) PushHandler(target int) {
+	vm.Handlers = append(vm.Handlers, Handler{target, len(vm.Frames)-1, len(vm.Lists), len(vm.Pending)})
+}
+
+// Run executes instructions until the frames above base have returned.
+func (vm *VM) Run(base int) (*Error) {
+	for len(vm.Frames) > base {
+		frame := &vm.Frames[len(vm.Frames)-1]
+		chunk := frame.Chunk
+		in := chunk.Code[frame.Ip]
+		frame.Ip++
+		var err *Error
+		switch in.Op {
+			case OP_PUSH:
+				vm.Scope.Stack = append(vm.Scope.Stack, chunk.Constants[in.A])
+			case OP_LIST_BEGIN:
+				vm.Lists = append(vm.Lists, vm.Scope.Stack)
+				vm.Scope.Stack = []AST{}
+			case OP_LIST_END:
+				list := AsList{vm.Scope.Stack}
+				vm.Scope.Stack = append(vm.Lists[len(vm.Lists)-1], list)
+				vm.Lists = vm.Lists[:len(vm.Lists)-1]
+			case OP_MAP_END:
+				elements := vm.Scope.Stack
+				vm.Scope.Stack = vm.Lists[len(vm.Lists)-1]
+				vm.Lists = vm.Lists[:len(vm.Lists)-1]
+				var value AST
+				if value, err = BuildMap(elements, chunk.Positions[frame.Ip-1]); err == nil {
+					vm.Scope.Stack = append(vm.Scope.Stack, value)
+				}
+			case OP_VAR, OP_TAIL_VAR:
+				value := vm.Lookup(in.A, in.B)
+				if value == nil {
+					err = vm.Scope.OpPush(Var{Name: vm.Names[in.A], Position: chunk.Positions[frame.Ip-1]}, nil)
+					break
+				}
+				if block, ok := value.(Blockdef); ok {
+					if in.Op == OP_TAIL_VAR && frame.Block != nil {
+						err = vm.TailCall(block, chunk.Positions[frame.Ip-1])
+					} else {
+						err = vm.Call(block, chunk.Positions[frame.Ip-1])
+					}
+					break
+				}
+				vm.Scope.Stack = append(vm.Scope.Stack, value)
+			case OP_VARDEF:
+				if len(vm.Scope.Stack) < 1 {
+					err = vm.Scope.OpVardef(vm.Names[in.A], chunk.Positions[frame.Ip-1], nil)
+					break
+				}
+				vm.Assign(in.A, in.B, vm.Pop())
+			case OP_ASSIGN:
+				var slot *AST
+				if in.B >= 0 && len(vm.Scope.Stack) > 0 {
+					slot = vm.RefSlot(frame, in.B)
+				}
+				if slot == nil {
+					err = vm.Scope.OpAssign(vm.Names[in.A], chunk.Positions[frame.Ip-1], nil)
+					vm.Globals[in.A] = vm.Scope.Variables[vm.Names[in.A]]
+					break
+				}
+				*slot = vm.Pop()
+			case OP_CLOSURE:
+				value := chunk.Constants[in.A]
+				if block, ok := value.(Blockdef); ok {
+					block.Env = frame.Env
+					value = block
+				} else {
+					quote := value.(AsQuote)
+					quote.Block.Env = frame.Env
+					value = quote
+				}
+				vm.Scope.Stack = append(vm.Scope.Stack, value)
+			case OP_MEMBER:
+				member := chunk.Members[in.A]
+				value := vm.Lookup(member.Global, member.Ref)
+				if value == nil {
+					err = vm.Scope.OpPush(member.Var, nil)
+					break
+				}
+				if value, err = LookupMember(value, member.Var); err != nil {
+					break
+				}
+				if block, ok := value.(Blockdef); ok {
+					if member.Var.Tail && frame.Block != nil {
+						err = vm.TailCall(block, member.Var.Position)
+					} else {
+						err = vm.Call(block, member.Var.Position)
+					}
+					break
+				}
+				vm.Scope.Stack = append(vm.Scope.Stack, value)
+			case OP_WORD:
+				err = chunk.Words[in.A](vm.Scope, chunk.Constants[in.B])
+			case OP_CALL:
+				var quote AsQuote
+				if quote, err = vm.Scope.PopQuote(chunk.Constants[in.A]); err != nil {
+					break
+				}
+				if in.B == 1 && frame.Block != nil {
+					err = vm.TailCall(quote.Block, chunk.Positions[frame.Ip-1])
+				} else {
+					err = vm.Call(quote.Block, chunk.Positions[frame.Ip-1])
+				}
+			case OP_BINOP:
+				err = vm.Scope.OpBinop(uint8(in.A), chunk.Positions[frame.Ip-1])
+			case OP_COMPARE:
+				err = vm.Scope.OpCompare(uint8(in.A), chunk.Positions[frame.Ip-1])
+			case OP_BLOCKDEF:
+				vm.SetGlobal(in.B, chunk.Constants[in.A])
+			case OP_ERRORDEF:
+				vm.Scope.Stack = append(vm.Scope.Stack, vm.Scope.DeclareError(chunk.Constants[in.A].(Errordef)))
+			case OP_INCLUDE:
+				include := chunk.Constants[in.A].(Include)
+				if frame.Calls+1 > vm.Scope.MaxDepth {
+					err = IncludeDepthErr(include.FileName, include.Position, vm.Scope.MaxDepth)
+					break
+				}
+				ast, IncludeErr := ParseInclude(include.FileName, include.Position)
+				if IncludeErr != nil {
+					err = IncludeErr
+					break
+				}
+				vm.Frames = append(vm.Frames, Frame{Chunk: vm.Compile(ast), Calls: frame.Calls+1})
+			case OP_IMPORT:
+				err = vm.Scope.OpImport(chunk.Constants[in.A].(Import), vm.RunModule)
+			case OP_ASSERT:
+				err = vm.Scope.OpAssert(chunk.Constants[in.A])
+			case OP_JUMP:
+				frame.Ip = in.A
+			case OP_JUMP_FALSE:
+				var cond bool
+				if in.B == CondFor {
+					cond, err = vm.Scope.OpCondition("for loop", chunk.Positions[frame.Ip-1])
+				} else {
+					cond, err = vm.Scope.OpCondition("if statement", chunk.Positions[frame.Ip-1])
+				}
+				if err == nil && !cond {
+					frame.Ip = in.A
+				}
+			case OP_TRY:
+				vm.PushHandler(in.A)
+			case OP_END_TRY:
+				vm.Handlers = vm.Handlers[:len(vm.Handlers)-1]
+				if in.B == 1 {
+					vm.Pending = append(vm.Pending, BreakPending)
+				} else {
+					vm.Pending = append(vm.Pending, nil)
+				}
+			case OP_CATCH:
+				err = vm.Catch(frame, chunk.Catches[in.A])
+			case OP_END_FINALLY:
+				err = vm.Pending[len(vm.Pending)-1]
+				vm.Pending = vm.Pending[:len(vm.Pending)-1]
+				if err == BreakPending || (err == nil && in.B == 1) {
+					err = nil
+					frame.Ip = in.A
+				}
+			case OP_RETURN:
+				vm.Frames = vm.Frames[:len(vm.Frames)-1]
+				if frame.Block != nil {
+					err = vm.Scope.CheckOutputs(*frame.Block, frame.Position, frame.Depth)
+					for i := len(frame.Checks)-1; i >= 0 && err == nil; i-- {
+						err = vm.Scope.CheckOutputs(frame.Checks[i].Block, frame.Checks[i].Position, frame.Checks[i].Depth)
+					}
+				}
+			default:
+				panic("unreachable")
+		}
+		if err != nil {
+			if err = vm.Throw(err, base); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func (vm *VM) Call(block Blockdef, position NodePosition) (*Error) {
+	calls := 1
+	if len(vm.Frames) > 0 {
+		calls = vm.Frames[len(vm.Frames)-1].Calls+1
+	}
+	if calls > vm.Scope.MaxDepth {
+		return RecursionErr(block, position, vm.Scope.MaxDepth)
+	}
+	depth, err := vm.Scope.CheckInputs(block, position)
+	if err != nil {
+		return err
+	}
+	chunk := vm.BlockChunk(block)
+	env := &Env{Slots: make([]AST, len(chunk.Locals)), Outer: block.Env}
+	vm.Frames = append(vm.Frames, Frame{chunk, 0, env, &block, position, depth, calls, nil, len(vm.Lists)})
+	return nil
+}
+
+// TailCall runs a block called in tail position in the frame of the
+// running block. The outputs of the running block are checked when the
+// new one returns.
+func (vm *VM) TailCall(block Blockdef, position NodePosition) (*Error) {
+	depth, err := vm.Scope.CheckInputs(block, position)
+	if err != nil {
+		return err
+	}
+	frame := &vm.Frames[len(vm.Frames)-1]
+	frame.Checks = AddCheck(frame.Checks, OutputCheck{*frame.Block, frame.Position, frame.Depth})
+	chunk := vm.BlockChunk(block)
+	frame.Chunk = chunk
+	frame.Ip = 0
+	frame.Env = &Env{Slots: make([]AST, len(chunk.Locals)), Outer: block.Env}
+	frame.Block = &block
+	frame.Position = position
+	frame.Depth = depth
+	return nil
+}
+
+// RunModule runs the file of a module, on a stack of its own.
+func (vm *VM) RunModule(ast AST, path string) (AsModule, *Error) {
+	chunk := vm.CompileModule(ast)
+	env := &Env{Slots: make([]AST, len(chunk.Locals))}
+	base, lists, pending := len(vm.Frames), len(vm.Lists), len(vm.Pending)
+	calls := 0
+	if base > 0 {
+		calls = vm.Frames[base-1].Calls
+	}
+	stack := vm.Scope.Stack
+	vm.Scope.Stack = []AST{}
+	vm.Frames = append(vm.Frames, Frame{Chunk: chunk, Env: env, Calls: calls, Lists: lists})
+	err := vm.Run(base)
+	if err != nil {
+		vm.Frames = vm.Frames[:base]
+		vm.Lists = vm.Lists[:lists]
+		vm.Pending = vm.Pending[:pending]
+	}
+	vm.Scope.Stack = stack
+	return AsModule{Env: env, Names: chunk.Locals}, err
+}
+
+// FailedStack is the stack of the innermost block call or module, or of
+// the program, without the lists that are not finished yet.
+func (vm *VM) FailedStack() []AST {
+	lists := 0
+	for i := len(vm.Frames)-1; i >= 0; i-- {
+		if vm.Frames[i].Block != nil || vm.Frames[i].Env != nil {
+			lists = vm.Frames[i].Lists
+			break
+		}
+	}
+	if len(vm.Lists) > lists {
+		return vm.Lists[lists]
+	}
+	return vm.Scope.Stack
+}
+
+// Trace returns the block calls of the frames, the most recent last.
+func (vm *VM) Trace() []CallSite {
+	trace := []CallSite{}
+	for i := 0; i < len(vm.Frames); i++ {
+		if vm.Frames[i].Block != nil {
+			trace = append(trace, CallSite{vm.Frames[i].Block.Name, vm.Frames[i].Position})
+		}
+	}
+	return trace
+}
+
+// CallBlock runs a block to the end, for the words that call a quotation
+// from Go. After an error the frames, lists and pending errors of the
+// block are dropped before the error goes back to the word.
+func (vm *VM) CallBlock(block Blockdef, position NodePosition) (*Error) {
+	base, lists, pending := len(vm.Frames), len(vm.Lists), len(vm.Pending)
+	if err := vm.Call(block, position); err != nil {
+		return err
+	}
+	if err := vm.Run(base); err != nil {
+		vm.Frames = vm.Frames[:base]
+		if len(vm.Lists) > lists {
+			vm.Scope.Stack = vm.Lists[lists]
+			vm.Lists = vm.Lists[:lists]
+		}
+		vm.Pending = vm.Pending[:pending]
+		return err
+	}
+	return nil
+}
+
+// Catch runs at the start of the except clauses, with the error of the
+// try body pending. It enters the first clause that matches, or goes on
+// to the finally body.
+func (vm *VM) Catch(frame *Frame, catch Catch) (*Error) {
+	err := vm.Pending[len(vm.Pending)-1]
+	frame.Ip = catch.Finally
+	if err == nil || err.Type == ExitSignal {
+		return nil
+	}
+	for i := 0; i < len(catch.Errors); i++ {
+		ErrorValue, ExceptErr := vm.ExceptError(catch.Errors[i], catch.ErrorSlots[i])
+		if ExceptErr != nil {
+			vm.Pending[len(vm.Pending)-1] = ExceptErr
+			return nil
+		}
+		if ErrorValue == err.Type || ErrorValue == ErrorVoid {
+			vm.Pending = vm.Pending[:len(vm.Pending)-1]
+			vm.PushHandler(catch.Finally)
+			value := AsError{err.Type, err.message, err.Position, err.Name}
+			if catch.Names[i] != "" {
+				vm.Assign(catch.NameSlots[i][0], catch.NameSlots[i][1], value)
+			} else {
+				vm.Scope.Stack = append(vm.Scope.Stack, value)
+			}
+			frame.Ip = catch.Targets[i]
+			return nil
+		}
+	}
+	return nil
+}
+
+func (vm *VM) ExceptError(node AST, slots [2]int) (ErrorType, *Error) {
+	VariableScope := &Env{Vars: map[string]AST{}}
+	if _, ok := node.(Var); ok {
+		if value := vm.Lookup(slots[0], slots[1]); value != nil {
+			VariableScope.Vars[node.(Var).Name] = value
+		}
+	}
+	return vm.Scope.OpExceptError(node, VariableScope)
+}